@@ -0,0 +1,225 @@
+package goflat_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lzambarda/goflat"
+)
+
+type benchRecord struct {
+	Name      string        `flat:"name"`
+	Age       int           `flat:"age"`
+	Height    float64       `flat:"height"`
+	Active    bool          `flat:"active"`
+	Comment   *string       `flat:"comment"`
+	Level     uint8         `flat:"level"`
+	CreatedAt time.Time     `flat:"created_at"`
+	Timeout   time.Duration `flat:"timeout"`
+}
+
+// benchGeneratedRecord has the same fields as benchRecord, with the code
+// generated by goflat-gen.
+type benchGeneratedRecord benchRecord
+
+//nolint:gochecknoglobals // Shared by the benchmarks.
+var benchSizes = []int{1, 10, 1000, 1_000_000}
+
+func benchRecords(n int) []benchRecord {
+	records := make([]benchRecord, n)
+
+	for i := range records {
+		records[i] = newBenchRecord(i)
+	}
+
+	return records
+}
+
+func newBenchRecord(i int) benchRecord {
+	return benchRecord{
+		Name:      "name" + strconv.Itoa(i),
+		Age:       i,
+		Height:    float64(i) / 10,
+		Active:    i%2 == 0,
+		Level:     uint8(i % 256), //nolint:gosec // Fine here.
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, i%60, 0, time.UTC),
+		Timeout:   time.Duration(i) * time.Millisecond,
+	}
+}
+
+// benchIterator yields n records without holding them in memory, as an
+// export from a database would.
+func benchIterator(n int) iter.Seq[benchRecord] {
+	return func(yield func(benchRecord) bool) {
+		for i := range n {
+			if !yield(newBenchRecord(i)) {
+				return
+			}
+		}
+	}
+}
+
+func benchCSV(n int) string {
+	var sb strings.Builder
+
+	sb.WriteString("name,age,height,active,comment,level,created_at,timeout\n")
+
+	for i := range n {
+		sb.WriteString("name" + strconv.Itoa(i) + "," + strconv.Itoa(i) + ",1.5,true,nil," +
+			strconv.Itoa(i%256) + ",2024-01-01T00:00:00Z,1.5s\n")
+	}
+
+	return sb.String()
+}
+
+func BenchmarkMarshalSliceToWriter(b *testing.B) {
+	for _, n := range benchSizes {
+		records := benchRecords(n)
+
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for b.Loop() {
+				err := goflat.MarshalSliceToWriter(b.Context(), records, csv.NewWriter(&bytes.Buffer{}), goflat.Options{})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshalToSlice(b *testing.B) {
+	for _, n := range benchSizes {
+		input := benchCSV(n)
+
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for b.Loop() {
+				_, err := goflat.UnmarshalToSlice[benchRecord](b.Context(), csv.NewReader(strings.NewReader(input)), goflat.Options{})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkMarshalParallel exports 10M rows per iteration, run it with
+// -benchtime=1x.
+func BenchmarkMarshalParallel(b *testing.B) {
+	const n = 10_000_000
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for b.Loop() {
+				err := goflat.MarshalIteratorToWriter(b.Context(), benchIterator(n), csv.NewWriter(io.Discard), goflat.Options{
+					Workers: workers,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshalParallel(b *testing.B) {
+	const n = 10_000
+
+	input := benchCSV(n)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for b.Loop() {
+				_, err := goflat.UnmarshalToSlice[benchRecord](b.Context(), csv.NewReader(strings.NewReader(input)), goflat.Options{
+					Workers: workers,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	const n = 1000
+
+	input := benchCSV(n)
+
+	for name, opts := range map[string]goflat.Options{
+		"reset":    {},
+		"preserve": {PreserveUnsetFields: true},
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for b.Loop() {
+				reader := csv.NewReader(strings.NewReader(input))
+				reader.ReuseRecord = true
+
+				decoder := goflat.NewDecoder[*benchRecord](reader, opts)
+
+				value := &benchRecord{}
+
+				for {
+					err := decoder.Decode(&value)
+					if errors.Is(err, io.EOF) {
+						break
+					}
+
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGenerated(b *testing.B) {
+	const n = 1000
+
+	input := benchCSV(n)
+	records := benchRecords(n)
+
+	generated := make([]benchGeneratedRecord, n)
+	for i, record := range records {
+		generated[i] = benchGeneratedRecord(record)
+	}
+
+	b.Run("marshal", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for b.Loop() {
+			err := goflat.MarshalSliceToWriter(b.Context(), generated, csv.NewWriter(&bytes.Buffer{}), goflat.Options{})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for b.Loop() {
+			_, err := goflat.UnmarshalToSlice[benchGeneratedRecord](b.Context(), csv.NewReader(strings.NewReader(input)), goflat.Options{})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package goflat
+
+import (
+	"cmp"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// structInfo holds the columns of a struct type. Since it only depends on
+// the type and on the options in its key, it is cached and shared by every
+// factory: it must not be modified once built.
+type structInfo struct {
+	key     structInfoKey
+	columns []*columnDescriptor
+	// extra is the catch-all map field for unmapped columns, if any.
+	extra *columnDescriptor
+	// repeated are the slice fields spread across numbered columns, see
+	// [repeatedField].
+	repeated []*repeatedField
+	// expanded are the map fields spread across one column per key, see
+	// [expandedField].
+	expanded []*expandedField
+	// pointerStructs are the paths to the nested structs which are pointers,
+	// see [nilGroup].
+	pointerStructs [][]int
+	// positions holds the index of each column in the marshalled row, which
+	// is width cells long before the extra columns.
+	positions []int
+	width     int
+	// flatMarshal and flatUnmarshal are true if the struct has code generated
+	// by goflat-gen, see [FlatMarshaller] and [FlatUnmarshaller].
+	flatMarshal   bool
+	flatUnmarshal bool
+}
+
+// structInfoKey identifies a [structInfo] in the cache. Options which only
+// affect header binding or the conversion of values are not part of it.
+type structInfoKey struct {
+	structType          reflect.Type
+	errorIfTaglessField bool
+	timeLayout          string
+	timeLocation        *time.Location
+	numberFormat        NumberFormat
+	// nilValue is [Options.NilValue] or its default, which the cells of
+	// slices holding items must not be mistaken for.
+	nilValue string
+}
+
+//nolint:gochecknoglobals // Cache shared by all the factories.
+var structInfoCache sync.Map // map[structInfoKey]*structInfo
+
+// loadStructInfo returns the columns of the given struct type, walking it
+// only the first time it is seen with the same options. Errors are not
+// cached.
+func loadStructInfo(t reflect.Type, options Options) (*structInfo, error) {
+	key := structInfoKey{
+		structType:          t,
+		errorIfTaglessField: options.ErrorIfTaglessField,
+		timeLayout:          options.TimeLayout,
+		timeLocation:        options.TimeLocation,
+		numberFormat:        options.NumberFormat,
+		nilValue:            cmp.Or(options.NilValue, defaultNilValue),
+	}
+
+	if info, ok := structInfoCache.Load(key); ok {
+		return info.(*structInfo), nil //nolint:forcetypeassert // Only *structInfo is stored.
+	}
+
+	info := &structInfo{key: key}
+
+	err := info.collectColumns(t, fieldPath{prefixes: []string{""}}, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = info.layoutPositions()
+	if err != nil {
+		return nil, err
+	}
+
+	info.flatMarshal = hasGeneratedCode(t, flatMarshallerType, info.columns)
+	info.flatUnmarshal = hasGeneratedCode(t, flatUnmarshallerType, info.columns)
+
+	actual, _ := structInfoCache.LoadOrStore(key, info)
+
+	return actual.(*structInfo), nil //nolint:forcetypeassert // Only *structInfo is stored.
+}
@@ -0,0 +1,104 @@
+package goflat
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type cached struct {
+	Name    string `flat:"name"`
+	Age     int    `flat:"age"`
+	Address struct {
+		City string `flat:"city"`
+	} `flat:"address"`
+}
+
+func TestStructInfoCache(t *testing.T) {
+	t.Run("shared", testStructInfoCacheShared)
+	t.Run("options", testStructInfoCacheOptions)
+	t.Run("concurrent", testStructInfoCacheConcurrent)
+}
+
+func testStructInfoCacheShared(t *testing.T) {
+	first, err := newFactory[cached]([]string{"name"}, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	second, err := newFactory[*cached]([]string{"age", "name"}, Options{ErrorIfMissingHeaders: false})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if &first.columns[0] != &second.columns[0] {
+		t.Errorf("expected columns to be shared")
+	}
+
+	if !reflect.DeepEqual(first.columnMap, map[int]int{0: 0}) {
+		t.Errorf("expected headers to be bound per factory, got %v", first.columnMap)
+	}
+}
+
+func testStructInfoCacheOptions(t *testing.T) {
+	first, err := newFactory[cached](nil, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	second, err := newFactory[cached](nil, Options{TimeLayout: "2006"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if &first.columns[0] == &second.columns[0] {
+		t.Errorf("expected columns not to be shared")
+	}
+}
+
+func testStructInfoCacheConcurrent(t *testing.T) {
+	type record struct {
+		Name string `flat:"name"`
+	}
+
+	var wg sync.WaitGroup
+
+	for range 16 {
+		wg.Go(func() {
+			_, err := newFactory[record]([]string{"name"}, Options{})
+			if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+
+	wg.Wait()
+}
+
+func BenchmarkNewFactory(b *testing.B) {
+	headers := []string{"name", "age", "address.city"}
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for b.Loop() {
+			_, err := newFactory[cached](headers, Options{})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for b.Loop() {
+			structInfoCache.Clear()
+
+			_, err := newFactory[cached](headers, Options{})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
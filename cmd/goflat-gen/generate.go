@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"maps"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// fieldTag is the tag goflat reads, see goflat.FieldTag.
+const fieldTag = "flat"
+
+var (
+	errTypeNotFound    = errors.New("type not found")
+	errNotAStruct      = errors.New("not a struct")
+	errUnsupportedType = errors.New("unsupported type")
+)
+
+// interfaceMethods are the methods which make goflat treat a struct as a
+// single column instead of flattening it.
+//
+//nolint:gochecknoglobals // Used for lookups.
+var interfaceMethods = []string{"Marshal", "Unmarshal", "MarshalText", "UnmarshalText"}
+
+// typeDecl is a type declared in the parsed directory.
+type typeDecl struct {
+	spec     *ast.TypeSpec
+	file     *ast.File
+	testFile bool
+}
+
+// packageInfo holds the types and methods declared in a directory, by
+// package name since test files can declare an external test package.
+type packageInfo struct {
+	fset    *token.FileSet
+	names   []string
+	types   map[string]map[string]typeDecl
+	methods map[string]map[string][]string
+}
+
+// step is a field of a nested struct along the path to a column.
+type step struct {
+	name    string
+	pointer bool
+	// elem is the type of the field, without the pointer.
+	elem ast.Expr
+	file *ast.File
+}
+
+// column is a field mapped to a column, in the same order as goflat collects
+// them.
+type column struct {
+	fieldName string
+	path      []step
+	leaf      step
+}
+
+func loadPackage(dir string) (*packageInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, fmt.Errorf("list files: %w", err)
+	}
+
+	slices.Sort(paths)
+
+	pkg := &packageInfo{
+		fset:    token.NewFileSet(),
+		types:   map[string]map[string]typeDecl{},
+		methods: map[string]map[string][]string{},
+	}
+
+	for _, path := range paths {
+		file, err := parser.ParseFile(pkg.fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+
+		pkg.add(file, strings.HasSuffix(path, "_test.go"))
+	}
+
+	return pkg, nil
+}
+
+func (p *packageInfo) add(file *ast.File, testFile bool) {
+	name := file.Name.Name
+
+	if _, ok := p.types[name]; !ok {
+		p.names = append(p.names, name)
+		p.types[name] = map[string]typeDecl{}
+		p.methods[name] = map[string][]string{}
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if spec, ok := spec.(*ast.TypeSpec); ok {
+					p.types[name][spec.Name.Name] = typeDecl{spec: spec, file: file, testFile: testFile}
+				}
+			}
+		case *ast.FuncDecl:
+			if decl.Recv == nil || len(decl.Recv.List) == 0 {
+				continue
+			}
+
+			recv := typeName(decl.Recv.List[0].Type)
+			p.methods[name][recv] = append(p.methods[name][recv], decl.Name.Name)
+		}
+	}
+}
+
+// lookup returns the declaration of the given type and the name of its
+// package.
+func (p *packageInfo) lookup(name string) (typeDecl, string, bool) {
+	for _, pkgName := range p.names {
+		if decl, ok := p.types[pkgName][name]; ok {
+			return decl, pkgName, true
+		}
+	}
+
+	return typeDecl{}, "", false
+}
+
+// generate returns the source of the methods of the given types, and whether
+// they are declared in test files.
+func (p *packageInfo) generate(typeNames []string) ([]byte, bool, error) {
+	var (
+		body     bytes.Buffer
+		pkgName  string
+		testFile bool
+		imports  = map[string]string{}
+	)
+
+	for _, name := range typeNames {
+		decl, declPkg, ok := p.lookup(name)
+		if !ok {
+			return nil, false, fmt.Errorf("type %q: %w", name, errTypeNotFound)
+		}
+
+		if pkgName != "" && declPkg != pkgName {
+			return nil, false, fmt.Errorf("type %q is in package %s, not %s: %w", name, declPkg, pkgName, errUnsupportedType)
+		}
+
+		pkgName, testFile = declPkg, decl.testFile
+
+		structType, structFile, _ := p.resolveStruct(pkgName, decl.spec.Type, decl.file)
+		if structType == nil {
+			return nil, false, fmt.Errorf("type %q: %w", name, errNotAStruct)
+		}
+
+		if decl.spec.TypeParams != nil {
+			return nil, false, fmt.Errorf("generic type %q: %w", name, errUnsupportedType)
+		}
+
+		columns, err := p.collectColumns(pkgName, structType, structFile, nil, "", map[string]bool{name: true})
+		if err != nil {
+			return nil, false, fmt.Errorf("type %q: %w", name, err)
+		}
+
+		p.writeMethods(&body, name, columns, imports)
+	}
+
+	var src bytes.Buffer
+
+	src.WriteString("// Code generated by goflat-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkgName)
+
+	if len(imports) > 0 {
+		src.WriteString("import (\n")
+
+		for _, path := range slices.Sorted(maps.Keys(imports)) {
+			if name := imports[path]; name != path[strings.LastIndex(path, "/")+1:] {
+				fmt.Fprintf(&src, "\t%s %q\n", name, path)
+			} else {
+				fmt.Fprintf(&src, "\t%q\n", path)
+			}
+		}
+
+		src.WriteString(")\n\n")
+	}
+
+	src.Write(body.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, false, fmt.Errorf("format: %w", err)
+	}
+
+	return formatted, testFile, nil
+}
+
+// collectColumns mirrors the way goflat walks the fields of a struct.
+//
+//nolint:cyclop,gocognit // Fine here, it follows goflat.
+func (p *packageInfo) collectColumns(
+	pkgName string,
+	structType *ast.StructType,
+	file *ast.File,
+	path []step,
+	fieldPrefix string,
+	visiting map[string]bool,
+) ([]column, error) {
+	var columns []column
+
+	for _, field := range structType.Fields.List {
+		tag, hasTag := lookupTag(field)
+		if hasTag && tag == "-" {
+			continue
+		}
+
+		anonymous := len(field.Names) == 0
+
+		names := []string{typeName(field.Type)}
+		if !anonymous {
+			names = names[:0]
+
+			for _, ident := range field.Names {
+				names = append(names, ident.Name)
+			}
+		}
+
+		elem, pointer := field.Type, false
+		if star, ok := elem.(*ast.StarExpr); ok {
+			elem, pointer = star.X, true
+		}
+
+		name, options := parseTag(tag)
+
+		// Structs with the json tag option are a single column.
+		nested, nestedFile, nestedName := p.resolveStruct(pkgName, elem, file)
+		flatten := nested != nil && !p.implementsInterface(pkgName, nestedName) && !options["json"]
+
+		for _, fieldName := range names {
+			exported := ast.IsExported(fieldName)
+			if !exported && !(anonymous && !pointer && nested != nil) {
+				continue
+			}
+
+			current := step{name: fieldName, pointer: pointer, elem: elem, file: file}
+
+			if flatten {
+				if name == "" && !anonymous {
+					continue
+				}
+
+				if visiting[nestedName] && nestedName != "" {
+					return nil, fmt.Errorf("type %s is recursive: %w", nestedName, errUnsupportedType)
+				}
+
+				visiting[nestedName] = true
+
+				nestedColumns, err := p.collectColumns(
+					pkgName,
+					nested,
+					nestedFile,
+					append(slices.Clone(path), current),
+					fieldPrefix+fieldName+".",
+					visiting,
+				)
+				if err != nil {
+					return nil, fmt.Errorf("field %q: %w", fieldName, err)
+				}
+
+				delete(visiting, nestedName)
+
+				columns = append(columns, nestedColumns...)
+
+				continue
+			}
+
+			// Extra maps, expanded maps and repeated fields are always
+			// handled through reflection.
+			if !exported || options["extra"] || options["expand"] || strings.Contains(name, "{n}") {
+				continue
+			}
+
+			positional := strings.HasPrefix(name, "#") || options["index"]
+			if name == "" && !positional {
+				continue
+			}
+
+			columns = append(columns, column{
+				fieldName: fieldPrefix + fieldName,
+				path:      path,
+				leaf:      current,
+			})
+		}
+	}
+
+	return columns, nil
+}
+
+// resolveStruct returns the struct type behind the given expression, with
+// the file declaring it and its name, if it is declared in the package.
+func (p *packageInfo) resolveStruct(pkgName string, expr ast.Expr, file *ast.File) (*ast.StructType, *ast.File, string) {
+	switch expr := expr.(type) {
+	case *ast.StructType:
+		return expr, file, ""
+	case *ast.Ident:
+		decl, ok := p.types[pkgName][expr.Name]
+		if !ok {
+			return nil, nil, ""
+		}
+
+		structType, declFile, _ := p.resolveStruct(pkgName, decl.spec.Type, decl.file)
+
+		return structType, declFile, expr.Name
+	}
+
+	return nil, nil, ""
+}
+
+// implementsInterface returns whether the named type has any of the methods
+// which make goflat treat it as a single column.
+func (p *packageInfo) implementsInterface(pkgName, name string) bool {
+	for _, method := range p.methods[pkgName][name] {
+		if slices.Contains(interfaceMethods, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *packageInfo) writeMethods(buf *bytes.Buffer, name string, columns []column, imports map[string]string) {
+	fmt.Fprintf(buf, "// FlatColumns implements goflat.FlatMarshaller and goflat.FlatUnmarshaller.\n")
+	fmt.Fprintf(buf, "func (*%s) FlatColumns() []string {\n\treturn []string{\n", name)
+
+	for _, column := range columns {
+		fmt.Fprintf(buf, "\t\t%q,\n", column.fieldName)
+	}
+
+	buf.WriteString("\t}\n}\n\n")
+
+	fmt.Fprintf(buf, "// MarshalFlat implements goflat.FlatMarshaller.\n")
+	fmt.Fprintf(buf, "func (v *%s) MarshalFlat(column int) any {\n\tswitch column {\n", name)
+
+	for i, column := range columns {
+		fmt.Fprintf(buf, "\tcase %d:\n", i)
+
+		selector := "v"
+
+		for _, step := range column.path {
+			selector += "." + step.name
+
+			if step.pointer {
+				fmt.Fprintf(buf, "\t\tif %s == nil {\n\t\t\treturn nil\n\t\t}\n\n", selector)
+			}
+		}
+
+		selector += "." + column.leaf.name
+
+		if column.leaf.pointer {
+			fmt.Fprintf(buf, "\t\treturn %s\n", selector)
+		} else {
+			fmt.Fprintf(buf, "\t\treturn &%s\n", selector)
+		}
+	}
+
+	buf.WriteString("\t}\n\n\treturn nil\n}\n\n")
+
+	fmt.Fprintf(buf, "// UnmarshalFlat implements goflat.FlatUnmarshaller.\n")
+	fmt.Fprintf(buf, "func (v *%s) UnmarshalFlat(column int) any {\n\tswitch column {\n", name)
+
+	for i, column := range columns {
+		fmt.Fprintf(buf, "\tcase %d:\n", i)
+
+		selector := "v"
+
+		for _, step := range column.path {
+			selector += "." + step.name
+
+			if step.pointer {
+				fmt.Fprintf(buf, "\t\tif %s == nil {\n\t\t\t%s = new(%s)\n\t\t}\n\n", selector, selector, p.typeString(step, imports))
+			}
+		}
+
+		selector += "." + column.leaf.name
+
+		if column.leaf.pointer {
+			// Like reflection, reuse the value of a non-nil pointer.
+			fmt.Fprintf(buf, "\t\tif %s == nil {\n\t\t\t%s = new(%s)\n\t\t}\n\n\t\treturn %s\n",
+				selector, selector, p.typeString(column.leaf, imports), selector)
+		} else {
+			fmt.Fprintf(buf, "\t\treturn &%s\n", selector)
+		}
+	}
+
+	buf.WriteString("\t}\n\n\treturn nil\n}\n\n")
+}
+
+// typeString prints the type of the given step, adding the packages it
+// references to the imports.
+func (p *packageInfo) typeString(s step, imports map[string]string) string {
+	ast.Inspect(s.elem, func(node ast.Node) bool {
+		selector, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		if ident, ok := selector.X.(*ast.Ident); ok {
+			if path, ok := importPath(s.file, ident.Name); ok {
+				imports[path] = ident.Name
+			}
+		}
+
+		return false
+	})
+
+	var buf bytes.Buffer
+
+	_ = format.Node(&buf, p.fset, s.elem)
+
+	return buf.String()
+}
+
+// importPath returns the path of the import with the given name in the file.
+func importPath(file *ast.File, name string) (string, bool) {
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		importName := path[strings.LastIndex(path, "/")+1:]
+		if spec.Name != nil {
+			importName = spec.Name.Name
+		}
+
+		if importName == name {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// typeName returns the name of a (pointer to a) named type.
+func typeName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return typeName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	case *ast.IndexExpr:
+		return typeName(expr.X)
+	case *ast.IndexListExpr:
+		return typeName(expr.X)
+	}
+
+	return ""
+}
+
+func lookupTag(field *ast.Field) (string, bool) {
+	if field.Tag == nil {
+		return "", false
+	}
+
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return "", false
+	}
+
+	return reflect.StructTag(tag).Lookup(fieldTag)
+}
+
+// parseTag returns the column name and the options of a tag. Option values
+// are ignored since only their presence matters here.
+func parseTag(tag string) (string, map[string]bool) {
+	var (
+		parts  []string
+		quoted bool
+		start  int
+	)
+
+	for i, r := range tag {
+		switch r {
+		case '\'':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				parts = append(parts, tag[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	parts = append(parts, tag[start:])
+
+	options := make(map[string]bool, len(parts)-1)
+
+	for _, part := range parts[1:] {
+		key, _, _ := strings.Cut(part, "=")
+		options[strings.TrimSpace(key)] = true
+	}
+
+	return parts[0], options
+}
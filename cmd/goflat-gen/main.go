@@ -0,0 +1,80 @@
+// Command goflat-gen generates the methods goflat uses to read and write the
+// fields of a struct without reflection, see goflat.FlatMarshaller and
+// goflat.FlatUnmarshaller.
+//
+// It is meant to be used with go:generate, in the package declaring the
+// structs:
+//
+//	//go:generate go run github.com/lzambarda/goflat/cmd/goflat-gen -type Record
+//
+// The generated methods only give access to the fields: the conversion of the
+// values and all the options are still handled by goflat, so the results are
+// the same as with reflection. If the struct changes and the code is not
+// generated again, goflat detects it and falls back to reflection.
+//
+// Only the struct types declared in the same package are flattened: fields
+// whose type is a struct from another package, other than those implementing
+// a goflat or text interface, are not supported.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var errUsage = errors.New("usage: goflat-gen -type T[,U...] [-output file] [dir]")
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of struct type names; must be set")
+	output := flag.String("output", "", "output file name; default <dir>/<type>_goflat.go")
+
+	flag.Parse()
+
+	if *typeNames == "" || flag.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, errUsage)
+		os.Exit(2) //nolint:mnd // Same as the flag package.
+	}
+
+	err := run(*typeNames, *output, flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "goflat-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(typeNames, output, dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+
+	pkg, err := loadPackage(dir)
+	if err != nil {
+		return err
+	}
+
+	src, testFile, err := pkg.generate(strings.Split(typeNames, ","))
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		name := strings.ToLower(strings.SplitN(typeNames, ",", 2)[0]) + "_goflat"
+		if testFile {
+			name += "_test"
+		}
+
+		output = filepath.Join(dir, name+".go")
+	}
+
+	//nolint:gosec,mnd // Same permissions as the other source files.
+	err = os.WriteFile(output, src, 0o644)
+	if err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("error", testGenerateError)
+	t.Run("success", testGenerateSuccess)
+	t.Run("up to date", testGenerateUpToDate)
+}
+
+func testGenerateError(t *testing.T) {
+	tcs := map[string]struct {
+		typeName string
+		expected error
+	}{
+		"not found": {
+			typeName: "Missing",
+			expected: errTypeNotFound,
+		},
+		"not a struct": {
+			typeName: "NotAStruct",
+			expected: errNotAStruct,
+		},
+		"recursive": {
+			typeName: "Recursive",
+			expected: errUnsupportedType,
+		},
+	}
+
+	pkg, err := loadPackage("testdata")
+	if err != nil {
+		t.Fatalf("load package: %v", err)
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := pkg.generate([]string{tc.typeName})
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func testGenerateSuccess(t *testing.T) {
+	pkg, err := loadPackage("testdata")
+	if err != nil {
+		t.Fatalf("load package: %v", err)
+	}
+
+	got, testFile, err := pkg.generate([]string{"Record"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if testFile {
+		t.Errorf("expected a non-test file")
+	}
+
+	expected, err := os.ReadFile(filepath.Join("testdata", "record_goflat.golden"))
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+
+	if diff := cmp.Diff(string(expected), string(got)); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+// testGenerateUpToDate checks that the code generated for the shared tests of
+// goflat is up to date.
+func testGenerateUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "..")
+
+	pkg, err := loadPackage(dir)
+	if err != nil {
+		t.Fatalf("load package: %v", err)
+	}
+
+	got, testFile, err := pkg.generate([]string{"generatedRecord", "benchGeneratedRecord"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !testFile {
+		t.Errorf("expected a test file")
+	}
+
+	expected, err := os.ReadFile(filepath.Join(dir, "generatedrecord_goflat_test.go"))
+	if err != nil {
+		t.Fatalf("read generated file: %v", err)
+	}
+
+	if diff := cmp.Diff(string(expected), string(got)); diff != "" {
+		t.Errorf("run go generate (-want +got):\n%s", diff)
+	}
+}
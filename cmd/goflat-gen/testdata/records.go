@@ -0,0 +1,51 @@
+package testdata
+
+import (
+	"net/netip"
+	stdtime "time"
+)
+
+type Base struct {
+	ID int64 `flat:"id"`
+}
+
+type Address struct {
+	City string `flat:"city"`
+}
+
+type Level int
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte{byte('0' + l)}, nil
+}
+
+type Wrapper struct {
+	Level Level `flat:"level"`
+}
+
+func (w Wrapper) MarshalText() ([]byte, error) {
+	return w.Level.MarshalText()
+}
+
+type Record struct {
+	*Base
+
+	Name     string        `flat:"name"`
+	First    string        `flat:"#3"`
+	Second   string        `flat:",index=4"`
+	Created  *stdtime.Time `flat:"created"`
+	Addr     netip.Addr    `flat:"addr"`
+	Home     Address       `flat:"home"`
+	Work     *Address      `flat:"work|office"`
+	Untagged Address
+	Wrapper  Wrapper           `flat:"wrapper"`
+	Extra    map[string]string `flat:",extra"`
+	Skipped  string            `flat:"-"`
+	private  string            `flat:"private"`
+}
+
+type Recursive struct {
+	Next *Recursive `flat:"next"`
+}
+
+type NotAStruct int
@@ -0,0 +1,515 @@
+package goflat
+
+import (
+	"bytes"
+	"cmp"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decodeFunc parses a string and sets it into the given addressable value.
+type decodeFunc func(str string, target reflect.Value) error
+
+// encodeFunc converts the given value, which is never a pointer, to a string.
+type encodeFunc func(value reflect.Value) (string, error)
+
+//nolint:gochecknoglobals // Used for type checks.
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	durationType = reflect.TypeFor[time.Duration]()
+	stringerType = reflect.TypeFor[fmt.Stringer]()
+)
+
+// compile builds the functions converting the column from and to strings.
+// They are built once per struct type, so that converting a cell needs
+// neither a type switch nor boxing the value into an interface.
+func (c *columnDescriptor) compile() {
+	t := c.reflectType
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if c.json {
+		c.decode, c.encode = jsonDecode, jsonEncode
+	} else {
+		c.decode = c.decoder(t)
+		c.encode = c.encoder(t)
+	}
+
+	c.compilePtr(t)
+}
+
+// jsonDecode parses a cell holding JSON, for fields with the json tag option.
+func jsonDecode(str string, target reflect.Value) error {
+	err := json.Unmarshal([]byte(str), target.Addr().Interface())
+	if err != nil {
+		return fmt.Errorf("unmarshal json: %w", err)
+	}
+
+	return nil
+}
+
+// jsonEncode converts the value to compact JSON, for fields with the json tag
+// option. HTML characters are not escaped, as the cells are not meant for
+// browsers.
+func jsonEncode(value reflect.Value) (string, error) {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	err := encoder.Encode(value.Interface())
+	if err != nil {
+		return "", fmt.Errorf("marshal json: %w", err)
+	}
+
+	// The encoder terminates each value with a newline.
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// decoder returns the decode function of the given type. The conversion logic
+// is picked in this order: [Unmarshaller], built-in handling of [time.Time]
+// and [time.Duration], [encoding.TextUnmarshaler] and finally the kind of the
+// type.
+//
+//nolint:cyclop,funlen // Fine here, it's a flat switch.
+func (c *columnDescriptor) decoder(t reflect.Type) decodeFunc {
+	switch {
+	case t.Kind() != reflect.Interface && t.Implements(unmarshallerType):
+		unmarshaller := reflect.Zero(t).Interface().(Unmarshaller) //nolint:forcetypeassert // Checked above.
+
+		return func(str string, target reflect.Value) error {
+			value, err := unmarshaller.Unmarshal(str)
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			target.Set(reflect.ValueOf(value))
+
+			return nil
+		}
+	case t == timeType:
+		layout, location := c.layout, cmp.Or(c.location, time.UTC)
+
+		return func(str string, target reflect.Value) error {
+			value, err := parseTime(str, layout, location)
+			if err != nil {
+				return err
+			}
+
+			*target.Addr().Interface().(*time.Time) = value //nolint:forcetypeassert // Checked above.
+
+			return nil
+		}
+	case t == durationType:
+		return func(str string, target reflect.Value) error {
+			value, err := time.ParseDuration(str)
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			target.SetInt(int64(value))
+
+			return nil
+		}
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return func(str string, target reflect.Value) error {
+			//nolint:forcetypeassert // Checked above.
+			err := target.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str))
+			if err != nil {
+				return fmt.Errorf("unmarshal text %q: %w", str, err)
+			}
+
+			return nil
+		}
+	}
+
+	if c.number != (NumberFormat{}) && isNumberKind(t.Kind()) {
+		return c.numberDecoder(t)
+	}
+
+	//nolint:exhaustive // Fine here, there's a default.
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(str string, target reflect.Value) error {
+			value, err := strconv.ParseBool(str)
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			target.SetBool(value)
+
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(str string, target reflect.Value) error {
+			value, err := strconv.ParseInt(str, 10, t.Bits())
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			target.SetInt(value)
+
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(str string, target reflect.Value) error {
+			value, err := strconv.ParseUint(str, 10, t.Bits())
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			target.SetUint(value)
+
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(str string, target reflect.Value) error {
+			value, err := strconv.ParseFloat(str, t.Bits())
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			target.SetFloat(value)
+
+			return nil
+		}
+	case reflect.String:
+		return func(str string, target reflect.Value) error {
+			target.SetString(str)
+
+			return nil
+		}
+	case reflect.Slice:
+		return c.sliceDecoder(t)
+	case reflect.Map:
+		return c.mapDecoder(t)
+	}
+
+	return func(string, reflect.Value) error {
+		return fmt.Errorf("type %s: %w", t, ErrUnsupportedType)
+	}
+}
+
+// sliceDecoder returns the decode function of a slice type, see
+// [sliceFormat].
+func (c *columnDescriptor) sliceDecoder(t reflect.Type) decodeFunc {
+	decodeItem := c.decoder(t.Elem())
+	format := c.slice
+
+	return func(str string, target reflect.Value) error {
+		items, err := format.split(str)
+		if err != nil {
+			return err
+		}
+
+		slice := reflect.MakeSlice(t, len(items), len(items))
+
+		for i, item := range items {
+			err := decodeItem(item, slice.Index(i))
+			if err != nil {
+				return fmt.Errorf("parse slice index %d, string %q: %w", i, item, err)
+			}
+		}
+
+		target.Set(slice)
+
+		return nil
+	}
+}
+
+// sliceEncoder returns the encode function of a slice type, see
+// [sliceFormat].
+func (c *columnDescriptor) sliceEncoder(t reflect.Type) encodeFunc {
+	encodeItem := c.encoder(t.Elem())
+	format := c.slice
+
+	return func(value reflect.Value) (string, error) {
+		items := make([]string, value.Len())
+
+		for i := range items {
+			str, err := encodeItem(value.Index(i))
+			if err != nil {
+				return "", fmt.Errorf("slice index %d: %w", i, err)
+			}
+
+			items[i] = str
+		}
+
+		return format.join(items), nil
+	}
+}
+
+// encoder returns the encode function of the given type. The conversion logic
+// is picked in this order: [Marshaller], built-in handling of [time.Time],
+// [encoding.TextMarshaler], [fmt.Stringer] and finally the default format of
+// the value.
+//
+//nolint:cyclop // Fine here, it's a flat switch.
+func (c *columnDescriptor) encoder(t reflect.Type) encodeFunc {
+	switch {
+	case implements(t, marshallerType):
+		return func(value reflect.Value) (string, error) {
+			m, _ := asInterface[Marshaller](value)
+
+			str, err := m.Marshal()
+			if err != nil {
+				return "", fmt.Errorf("marshal: %w", err)
+			}
+
+			return str, nil
+		}
+	case t == timeType:
+		layout, location := c.layout, c.location
+
+		return func(value reflect.Value) (string, error) {
+			return formatTime(value.Interface().(time.Time), layout, location), nil //nolint:forcetypeassert // Checked above.
+		}
+	case implements(t, textMarshalerType):
+		return func(value reflect.Value) (string, error) {
+			m, _ := asInterface[encoding.TextMarshaler](value)
+
+			text, err := m.MarshalText()
+			if err != nil {
+				return "", fmt.Errorf("marshal text: %w", err)
+			}
+
+			return string(text), nil
+		}
+	case implements(t, stringerType):
+		return func(value reflect.Value) (string, error) {
+			s, _ := asInterface[fmt.Stringer](value)
+
+			return s.String(), nil
+		}
+	}
+
+	if c.number != (NumberFormat{}) && isNumberKind(t.Kind()) {
+		return c.numberEncoder(t)
+	}
+
+	//nolint:exhaustive // Fine here, there's a default.
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(value reflect.Value) (string, error) {
+			return strconv.FormatBool(value.Bool()), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(value reflect.Value) (string, error) {
+			return strconv.FormatInt(value.Int(), 10), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(value reflect.Value) (string, error) {
+			return strconv.FormatUint(value.Uint(), 10), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(value reflect.Value) (string, error) {
+			return strconv.FormatFloat(value.Float(), 'g', -1, t.Bits()), nil
+		}
+	case reflect.String:
+		return func(value reflect.Value) (string, error) {
+			return value.String(), nil
+		}
+	case reflect.Slice:
+		return c.sliceEncoder(t)
+	case reflect.Map:
+		return c.mapEncoder(t)
+	}
+
+	return func(value reflect.Value) (string, error) {
+		return fmt.Sprintf("%v", value.Interface()), nil
+	}
+}
+
+// implements returns whether either the given type or a pointer to it
+// implement the interface type. Interface types are left to the default
+// format, which also handles nil values.
+func implements(t, interfaceType reflect.Type) bool {
+	if t.Kind() == reflect.Interface {
+		return false
+	}
+
+	return t.Implements(interfaceType) || reflect.PointerTo(t).Implements(interfaceType)
+}
+
+// ptrDecodeFunc and ptrEncodeFunc work like [decodeFunc] and [encodeFunc],
+// but on a pointer to the value such as the ones returned by the code
+// generated by goflat-gen.
+type (
+	ptrDecodeFunc func(str string, ptr any) error
+	ptrEncodeFunc func(ptr any) (string, error)
+)
+
+// compilePtr builds the functions converting the column through a pointer.
+// Built-in types, [time.Time] and [time.Duration] are converted with a type
+// assertion, while any other type, or any column with the json tag option or
+// a [NumberFormat], falls back to the reflection based functions.
+//
+//nolint:cyclop // Fine here, it's a flat switch.
+func (c *columnDescriptor) compilePtr(t reflect.Type) {
+	if c.json || c.number != (NumberFormat{}) {
+		t = nil
+	}
+
+	switch t {
+	case reflect.TypeFor[string]():
+		c.decodePtr, c.encodePtr = stringPtrCodec()
+	case reflect.TypeFor[bool]():
+		c.decodePtr, c.encodePtr = boolPtrCodec()
+	case reflect.TypeFor[int]():
+		c.decodePtr, c.encodePtr = intPtrCodec[int]()
+	case reflect.TypeFor[int8]():
+		c.decodePtr, c.encodePtr = intPtrCodec[int8]()
+	case reflect.TypeFor[int16]():
+		c.decodePtr, c.encodePtr = intPtrCodec[int16]()
+	case reflect.TypeFor[int32]():
+		c.decodePtr, c.encodePtr = intPtrCodec[int32]()
+	case reflect.TypeFor[int64]():
+		c.decodePtr, c.encodePtr = intPtrCodec[int64]()
+	case reflect.TypeFor[uint]():
+		c.decodePtr, c.encodePtr = uintPtrCodec[uint]()
+	case reflect.TypeFor[uint8]():
+		c.decodePtr, c.encodePtr = uintPtrCodec[uint8]()
+	case reflect.TypeFor[uint16]():
+		c.decodePtr, c.encodePtr = uintPtrCodec[uint16]()
+	case reflect.TypeFor[uint32]():
+		c.decodePtr, c.encodePtr = uintPtrCodec[uint32]()
+	case reflect.TypeFor[uint64]():
+		c.decodePtr, c.encodePtr = uintPtrCodec[uint64]()
+	case reflect.TypeFor[float32]():
+		c.decodePtr, c.encodePtr = floatPtrCodec[float32]()
+	case reflect.TypeFor[float64]():
+		c.decodePtr, c.encodePtr = floatPtrCodec[float64]()
+	case timeType:
+		c.decodePtr, c.encodePtr = timePtrCodec(c.layout, c.location)
+	case durationType:
+		c.decodePtr, c.encodePtr = durationPtrCodec()
+	default:
+		decode, encode := c.decode, c.encode
+
+		c.decodePtr = func(str string, ptr any) error {
+			return decode(str, reflect.ValueOf(ptr).Elem())
+		}
+		c.encodePtr = func(ptr any) (string, error) {
+			return encode(reflect.ValueOf(ptr).Elem())
+		}
+	}
+}
+
+//nolint:forcetypeassert // The type is checked by compilePtr.
+func stringPtrCodec() (ptrDecodeFunc, ptrEncodeFunc) {
+	return func(str string, ptr any) error {
+			*ptr.(*string) = str
+
+			return nil
+		}, func(ptr any) (string, error) {
+			return *ptr.(*string), nil
+		}
+}
+
+//nolint:forcetypeassert // The type is checked by compilePtr.
+func boolPtrCodec() (ptrDecodeFunc, ptrEncodeFunc) {
+	return func(str string, ptr any) error {
+			value, err := strconv.ParseBool(str)
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			*ptr.(*bool) = value
+
+			return nil
+		}, func(ptr any) (string, error) {
+			return strconv.FormatBool(*ptr.(*bool)), nil
+		}
+}
+
+//nolint:forcetypeassert // The type is checked by compilePtr.
+func intPtrCodec[I int | int8 | int16 | int32 | int64]() (ptrDecodeFunc, ptrEncodeFunc) {
+	bits := reflect.TypeFor[I]().Bits()
+
+	return func(str string, ptr any) error {
+			value, err := strconv.ParseInt(str, 10, bits)
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			*ptr.(*I) = I(value)
+
+			return nil
+		}, func(ptr any) (string, error) {
+			return strconv.FormatInt(int64(*ptr.(*I)), 10), nil
+		}
+}
+
+//nolint:forcetypeassert // The type is checked by compilePtr.
+func uintPtrCodec[U uint | uint8 | uint16 | uint32 | uint64]() (ptrDecodeFunc, ptrEncodeFunc) {
+	bits := reflect.TypeFor[U]().Bits()
+
+	return func(str string, ptr any) error {
+			value, err := strconv.ParseUint(str, 10, bits)
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			*ptr.(*U) = U(value)
+
+			return nil
+		}, func(ptr any) (string, error) {
+			return strconv.FormatUint(uint64(*ptr.(*U)), 10), nil
+		}
+}
+
+//nolint:forcetypeassert // The type is checked by compilePtr.
+func floatPtrCodec[F float32 | float64]() (ptrDecodeFunc, ptrEncodeFunc) {
+	bits := reflect.TypeFor[F]().Bits()
+
+	return func(str string, ptr any) error {
+			value, err := strconv.ParseFloat(str, bits)
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			*ptr.(*F) = F(value)
+
+			return nil
+		}, func(ptr any) (string, error) {
+			return strconv.FormatFloat(float64(*ptr.(*F)), 'g', -1, bits), nil
+		}
+}
+
+//nolint:forcetypeassert // The type is checked by compilePtr.
+func timePtrCodec(layout string, location *time.Location) (ptrDecodeFunc, ptrEncodeFunc) {
+	return func(str string, ptr any) error {
+			value, err := parseTime(str, layout, cmp.Or(location, time.UTC))
+			if err != nil {
+				return err
+			}
+
+			*ptr.(*time.Time) = value
+
+			return nil
+		}, func(ptr any) (string, error) {
+			return formatTime(*ptr.(*time.Time), layout, location), nil
+		}
+}
+
+//nolint:forcetypeassert // The type is checked by compilePtr.
+func durationPtrCodec() (ptrDecodeFunc, ptrEncodeFunc) {
+	return func(str string, ptr any) error {
+			value, err := time.ParseDuration(str)
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			*ptr.(*time.Duration) = value
+
+			return nil
+		}, func(ptr any) (string, error) {
+			return ptr.(*time.Duration).String(), nil
+		}
+}
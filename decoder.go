@@ -0,0 +1,192 @@
+package goflat
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Decoder reads and unmarshals rows one at a time, like [json.Decoder]. The
+// headers are read with the first row, unless [Options.Headerless] is set.
+//
+// [Options.Workers] and [Options.CollectErrors] have no effect: rows are
+// decoded on the caller's goroutine and errors are returned by
+// [Decoder.Decode].
+type Decoder[T any] struct {
+	reader  RowReader
+	options Options
+	factory *structFactory[T]
+	lines   lineFunc
+	// err prevents any further reading, it is [io.EOF] at the end of the
+	// file.
+	err error
+	// next is the row read ahead by More, if peeked is true.
+	next    []string
+	nextErr error
+	peeked  bool
+	line    int
+}
+
+// NewDecoder returns a decoder reading from the given reader. Nothing is read
+// until the first call to one of its methods.
+//
+// An options struct can be passed to modify the behaviour, use [StrictOptions]
+// if you're not sure about how to configure them.
+func NewDecoder[T any](reader RowReader, opts Options) *Decoder[T] {
+	return &Decoder[T]{
+		reader:  reader,
+		options: opts,
+	}
+}
+
+// init reads the headers and binds them to the struct, the first time it is
+// called.
+func (d *Decoder[T]) init() error {
+	if d.factory != nil || d.err != nil {
+		return d.err
+	}
+
+	err := checkReader(d.reader, d.options)
+	if err != nil {
+		d.err = err
+
+		return d.err
+	}
+
+	var headers []string
+
+	if !d.options.Headerless {
+		record, err := d.reader.Read()
+		if err != nil {
+			d.err = fmt.Errorf("read headers: %w", err)
+
+			return d.err
+		}
+
+		// The headers are needed for the whole file, while the next Read may
+		// overwrite the record.
+		headers = slices.Clone(record)
+	}
+
+	factory, err := newFactory[T](headers, d.options)
+	if err != nil {
+		d.err = fmt.Errorf("new factory: %w", err)
+
+		return d.err
+	}
+
+	d.factory = factory
+	d.lines = readerLines(d.reader)
+
+	return nil
+}
+
+// Headers returns the headers of the file, reading them if needed. With
+// [Options.Headerless], there are none.
+func (d *Decoder[T]) Headers() ([]string, error) {
+	err := d.init()
+	if err != nil {
+		return nil, err
+	}
+
+	return d.factory.headers, nil
+}
+
+// Decode unmarshals the next row into the value pointed to by v, which is
+// zeroed first unless [Options.PreserveUnsetFields] is set. It returns
+// [io.EOF] once there are no more rows.
+//
+// Decoding into the same value for every row avoids allocating: if T is a
+// pointer, the struct it points to is reused and, with
+// [Options.PreserveUnsetFields], so are the values of non-nil pointer fields.
+// Any copy of them must therefore be made before the next call.
+//
+// Errors about a single row, such as a [*ParseError] or a [*csv.ParseError],
+// leave the decoder usable: the next call moves on to the next row, while v
+// may be partially set. Any other error is returned again by every
+// subsequent call.
+func (d *Decoder[T]) Decode(v *T) error {
+	err := d.init()
+	if err != nil {
+		return err
+	}
+
+	record, err := d.read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			d.err = io.EOF
+
+			return io.EOF
+		}
+
+		err = fmt.Errorf("read row: %w", err)
+
+		// Only errors about the content of the file are recoverable.
+		var parseErr *csv.ParseError
+		if !errors.As(err, &parseErr) {
+			d.err = err
+		}
+
+		return err
+	}
+
+	d.line = 0
+	if d.lines != nil && len(record) > 0 {
+		d.line = d.lines(0)
+	}
+
+	if !d.options.PreserveUnsetFields {
+		d.factory.reset(v)
+	}
+
+	err = d.factory.unmarshalInto(v, record)
+	if err != nil {
+		return withLine(err, d.lines)
+	}
+
+	return nil
+}
+
+// read returns the next row, either read ahead by More or from the reader.
+func (d *Decoder[T]) read() ([]string, error) {
+	if !d.peeked {
+		return d.reader.Read() //nolint:wrapcheck // Wrapped by the caller.
+	}
+
+	record, err := d.next, d.nextErr
+	d.next, d.nextErr, d.peeked = nil, nil, false
+
+	return record, err
+}
+
+// More reports whether there is another row to decode, reading it ahead if
+// needed. If reading fails, More returns true and the error is returned by
+// the next call to [Decoder.Decode].
+func (d *Decoder[T]) More() bool {
+	if d.err != nil {
+		return false
+	}
+
+	if d.init() != nil || d.peeked {
+		return true
+	}
+
+	d.next, d.nextErr = d.reader.Read()
+	if errors.Is(d.nextErr, io.EOF) {
+		d.err = io.EOF
+
+		return false
+	}
+
+	d.peeked = true
+
+	return true
+}
+
+// Line returns the line on which the row returned by the last call to
+// [Decoder.Decode] starts, or 0 if the reader cannot report it.
+func (d *Decoder[T]) Line() int {
+	return d.line
+}
@@ -0,0 +1,303 @@
+package goflat_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/lzambarda/goflat"
+)
+
+func TestDecoder(t *testing.T) {
+	t.Run("decode", testDecoderDecode)
+	t.Run("reuse", testDecoderReuse)
+	t.Run("preserve", testDecoderPreserve)
+	t.Run("pointer", testDecoderPointer)
+	t.Run("errors", testDecoderErrors)
+	t.Run("headerless", testDecoderHeaderless)
+}
+
+type decoderRecord struct {
+	Name string `flat:"name"`
+	Age  int    `flat:"age"`
+}
+
+func testDecoderDecode(t *testing.T) {
+	input := `name,age
+Guybrush,28
+"Elaine
+Marley",20
+`
+
+	decoder := goflat.NewDecoder[decoderRecord](csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+
+	headers, err := decoder.Headers()
+	if err != nil {
+		t.Fatalf("headers: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"name", "age"}, headers); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+
+	var (
+		got   []decoderRecord
+		lines []int
+	)
+
+	for decoder.More() {
+		var value decoderRecord
+
+		err := decoder.Decode(&value)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+
+		got = append(got, value)
+		lines = append(lines, decoder.Line())
+	}
+
+	expected := []decoderRecord{
+		{Name: "Guybrush", Age: 28},
+		{Name: "Elaine\nMarley", Age: 20},
+	}
+
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]int{2, 3}, lines); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+
+	var value decoderRecord
+
+	err = decoder.Decode(&value)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+}
+
+func testDecoderReuse(t *testing.T) {
+	input := `name,age
+Guybrush,28
+Elaine,
+`
+
+	decoder := goflat.NewDecoder[decoderRecord](csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+		UnmarshalIgnoreEmpty: true,
+	})
+
+	var (
+		value decoderRecord
+		got   []decoderRecord
+	)
+
+	for {
+		err := decoder.Decode(&value)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+
+		got = append(got, value)
+	}
+
+	// Values are zeroed before decoding: the age is not carried over.
+	expected := []decoderRecord{
+		{Name: "Guybrush", Age: 28},
+		{Name: "Elaine"},
+	}
+
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+}
+
+func testDecoderPreserve(t *testing.T) {
+	type record struct {
+		Name     string            `flat:"name"`
+		Age      int               `flat:"age"`
+		Nickname *string           `flat:"nickname"`
+		Ship     string            `flat:"ship"`
+		Extra    map[string]string `flat:",extra"`
+	}
+
+	input := `name,age,nickname,origin
+Guybrush,28,mighty pirate,Melee
+Elaine,,nil,
+`
+
+	reader := csv.NewReader(bytes.NewBufferString(input))
+	reader.ReuseRecord = true
+
+	decoder := goflat.NewDecoder[record](reader, goflat.Options{
+		UnmarshalIgnoreEmpty: true,
+		PreserveUnsetFields:  true,
+	})
+
+	// The ship has no column, so it is never overwritten.
+	value := record{Ship: "The Sea Cucumber"}
+
+	err := decoder.Decode(&value)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	nickname := value.Nickname
+
+	err = decoder.Decode(&value)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	// The empty age is skipped, while nil still clears the nickname.
+	expected := record{
+		Name:  "Elaine",
+		Age:   28,
+		Ship:  "The Sea Cucumber",
+		Extra: map[string]string{},
+	}
+
+	if diff := cmp.Diff(expected, value); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+
+	if nickname == nil || *nickname != "mighty pirate" {
+		t.Errorf("expected the previous nickname to be left alone, got %v", nickname)
+	}
+}
+
+func testDecoderPointer(t *testing.T) {
+	input := `name,age
+Guybrush,28
+Elaine,20
+`
+
+	decoder := goflat.NewDecoder[*decoderRecord](csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+
+	value := &decoderRecord{}
+	buffer := value
+
+	for _, expected := range []decoderRecord{{Name: "Guybrush", Age: 28}, {Name: "Elaine", Age: 20}} {
+		err := decoder.Decode(&value)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+
+		if value != buffer {
+			t.Fatal("expected the struct to be reused")
+		}
+
+		if diff := cmp.Diff(expected, *value); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	}
+}
+
+func testDecoderErrors(t *testing.T) {
+	t.Run("row", func(t *testing.T) {
+		input := `name,age
+Guybrush,twenty
+Elaine,20
+`
+
+		decoder := goflat.NewDecoder[decoderRecord](csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+
+		var value decoderRecord
+
+		err := decoder.Decode(&value)
+
+		var parseErr *goflat.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("expected %T, got %v", parseErr, err)
+		}
+
+		if parseErr.Line != 2 {
+			t.Errorf("expected line 2, got %d", parseErr.Line)
+		}
+
+		// The decoder moves on to the next row.
+		if !decoder.More() {
+			t.Fatal("expected more rows")
+		}
+
+		err = decoder.Decode(&value)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+
+		if diff := cmp.Diff(decoderRecord{Name: "Elaine", Age: 20}, value); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		decoder := goflat.NewDecoder[decoderRecord](csv.NewReader(&bytes.Buffer{}), goflat.Options{})
+
+		// Failing to read the headers is reported by Decode, every time.
+		if !decoder.More() {
+			t.Fatal("expected more to be true")
+		}
+
+		var value decoderRecord
+
+		for range 2 {
+			err := decoder.Decode(&value)
+			if !errors.Is(err, io.EOF) {
+				t.Errorf("expected %v, got %v", io.EOF, err)
+			}
+		}
+
+		if decoder.More() {
+			t.Error("expected more to be false")
+		}
+	})
+}
+
+func testDecoderHeaderless(t *testing.T) {
+	reader := &tableReader{}
+	for i := range 3 {
+		reader.rows = append(reader.rows, []string{"name" + strconv.Itoa(i), strconv.Itoa(i)})
+	}
+
+	decoder := goflat.NewDecoder[decoderRecord](goflat.WithHeaders(reader, "name", "age"), goflat.Options{})
+
+	headers, err := decoder.Headers()
+	if err != nil {
+		t.Fatalf("headers: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"name", "age"}, headers); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+
+	var got []decoderRecord
+
+	for decoder.More() {
+		var value decoderRecord
+
+		err := decoder.Decode(&value)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+
+		got = append(got, value)
+
+		if decoder.Line() != 0 {
+			t.Errorf("expected no line, got %d", decoder.Line())
+		}
+	}
+
+	if len(got) != 3 || got[2].Name != "name2" {
+		t.Errorf("unexpected values: %v", got)
+	}
+}
@@ -0,0 +1,142 @@
+package goflat
+
+import "fmt"
+
+// Encoder marshals and writes rows one at a time, like [json.Encoder]. The
+// header row is written before the first row, unless [Options.Headerless] is
+// set, which allows appending to an existing file.
+//
+// Rows are marshalled on the caller's goroutine, regardless of
+// [Options.Workers]. The writer must be flushed with [Encoder.Flush] once
+// done.
+type Encoder[T any] struct {
+	writer         RowWriter
+	options        Options
+	factory        *structFactory[T]
+	err            error
+	headersWritten bool
+	// row is the index of the next row, for errors.
+	row int
+}
+
+// NewEncoder returns an encoder writing to the given writer. Errors about the
+// struct type are returned by each of its methods.
+//
+// An options struct can be passed to modify the behaviour, use [StrictOptions]
+// if you're not sure about how to configure them.
+func NewEncoder[T any](writer RowWriter, opts Options) *Encoder[T] {
+	opts.headersFromStruct = true
+
+	factory, err := newFactory[T](nil, opts)
+	if err != nil {
+		err = fmt.Errorf("new factory: %w", err)
+	}
+
+	if _, ok := writer.(*FixedWidthWriter); ok && opts.Headerless {
+		err = fmt.Errorf("headerless fixed-width writer: %w", ErrFixedWidthHeaders)
+	}
+
+	return &Encoder[T]{
+		writer:  writer,
+		options: opts,
+		factory: factory,
+		err:     err,
+	}
+}
+
+// WriteHeader writes the header row, unless it was already written. It is
+// only needed to write the headers of a file without rows, since
+// [Encoder.Encode] and [Encoder.EncodeAll] write them as needed.
+//
+// If the struct has a field tagged with the `extra` option, no extra columns
+// are written: use [Encoder.EncodeAll] to compute them from the values.
+func (e *Encoder[T]) WriteHeader() error {
+	return e.writeHeader()
+}
+
+// writeHeader writes the header row, if not written yet. The extra columns,
+// if any, are computed from the given samples.
+func (e *Encoder[T]) writeHeader(samples ...T) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	if e.headersWritten {
+		return nil
+	}
+
+	e.headersWritten = true
+
+	// Headers are needed even for headerless files, to lay out the extra
+	// columns.
+	headers := e.factory.marshalHeaders(samples...)
+	if e.options.Headerless {
+		return nil
+	}
+
+	err := e.writer.Write(headers)
+	if err != nil {
+		return fmt.Errorf("write headers: %w", err)
+	}
+
+	return nil
+}
+
+// Encode marshals and writes a row, writing the header row first if needed.
+//
+// If the struct has a field tagged with the `extra` option, the keys of its
+// map in the first value determine the additional columns: any subsequent
+// value introducing a new key causes [ErrUnknownColumn].
+func (e *Encoder[T]) Encode(value T) error {
+	err := e.writeHeader(value)
+	if err != nil {
+		return err
+	}
+
+	record, err := e.factory.marshal(&value)
+	if err != nil {
+		return marshalRowError(err, e.row)
+	}
+
+	return e.writeRecord(record)
+}
+
+// EncodeAll works like [Encoder.Encode] for each value, but all of them are
+// used to compute the extra columns, if the header row is not written yet.
+func (e *Encoder[T]) EncodeAll(values []T) error {
+	err := e.writeHeader(values...)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		err = e.Encode(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeRecord writes a marshalled row.
+func (e *Encoder[T]) writeRecord(record []string) error {
+	err := e.writer.Write(record)
+	if err != nil {
+		return fmt.Errorf("write row %d: %w", e.row, err)
+	}
+
+	e.row++
+
+	return nil
+}
+
+// Flush flushes the writer, if it buffers rows, see [RowWriter].
+func (e *Encoder[T]) Flush() error {
+	err := flush(e.writer)
+	if err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package goflat_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/lzambarda/goflat"
+)
+
+func TestEncoder(t *testing.T) {
+	t.Run("encode", testEncoderEncode)
+	t.Run("encode all", testEncoderEncodeAll)
+	t.Run("write header", testEncoderWriteHeader)
+	t.Run("append", testEncoderAppend)
+	t.Run("error", testEncoderError)
+}
+
+type encoderRecord struct {
+	Name  string         `flat:"name"`
+	Value marshalFailing `flat:"value"`
+}
+
+func testEncoderEncode(t *testing.T) {
+	writer := &tableWriter{}
+	encoder := goflat.NewEncoder[encoderRecord](writer, goflat.Options{})
+
+	for _, name := range []string{"Guybrush", "Elaine"} {
+		err := encoder.Encode(encoderRecord{Name: name})
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+	}
+
+	if len(writer.rows) != 0 {
+		t.Errorf("expected no rows before flushing, got %v", writer.rows)
+	}
+
+	err := encoder.Flush()
+	if err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	expected := [][]string{
+		{"name", "value"},
+		{"Guybrush", "ok"},
+		{"Elaine", "ok"},
+	}
+
+	if diff := cmp.Diff(expected, writer.rows); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+}
+
+func testEncoderEncodeAll(t *testing.T) {
+	type record struct {
+		Name  string            `flat:"name"`
+		Extra map[string]string `flat:",extra"`
+	}
+
+	var got bytes.Buffer
+
+	encoder := goflat.NewEncoder[record](csv.NewWriter(&got), goflat.Options{})
+
+	// All the values are used to compute the extra columns.
+	err := encoder.EncodeAll([]record{
+		{Name: "Guybrush", Extra: map[string]string{"ship": "The Sea Cucumber"}},
+		{Name: "LeChuck", Extra: map[string]string{"curse": "ghost"}},
+	})
+	if err != nil {
+		t.Fatalf("encode all: %v", err)
+	}
+
+	err = encoder.Encode(record{Name: "Elaine"})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	err = encoder.Flush()
+	if err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	expected := `name,curse,ship
+Guybrush,,The Sea Cucumber
+LeChuck,ghost,
+Elaine,,
+`
+
+	if diff := cmp.Diff(expected, got.String()); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+}
+
+func testEncoderWriteHeader(t *testing.T) {
+	writer := &tableWriter{}
+	encoder := goflat.NewEncoder[encoderRecord](writer, goflat.Options{})
+
+	// The header row is only written once.
+	for range 2 {
+		err := encoder.WriteHeader()
+		if err != nil {
+			t.Fatalf("write header: %v", err)
+		}
+	}
+
+	err := encoder.Flush()
+	if err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	if diff := cmp.Diff([][]string{{"name", "value"}}, writer.rows); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+}
+
+func testEncoderAppend(t *testing.T) {
+	writer := &tableWriter{rows: [][]string{{"name", "value"}, {"Guybrush", "ok"}}}
+	encoder := goflat.NewEncoder[encoderRecord](writer, goflat.Options{Headerless: true})
+
+	err := encoder.Encode(encoderRecord{Name: "Elaine"})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	err = encoder.Flush()
+	if err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	expected := [][]string{
+		{"name", "value"},
+		{"Guybrush", "ok"},
+		{"Elaine", "ok"},
+	}
+
+	if diff := cmp.Diff(expected, writer.rows); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+}
+
+func testEncoderError(t *testing.T) {
+	t.Run("marshal", func(t *testing.T) {
+		encoder := goflat.NewEncoder[encoderRecord](&tableWriter{}, goflat.Options{})
+
+		err := encoder.Encode(encoderRecord{Name: "Guybrush"})
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+
+		err = encoder.Encode(encoderRecord{Name: "Elaine", Value: marshalFailing{Fail: true}})
+
+		var marshalErr *goflat.MarshalError
+		if !errors.As(err, &marshalErr) {
+			t.Fatalf("expected %T, got %v", marshalErr, err)
+		}
+
+		// Rows are counted across calls.
+		if marshalErr.Row != 1 {
+			t.Errorf("expected row 1, got %d", marshalErr.Row)
+		}
+
+		// The encoder is still usable.
+		err = encoder.Encode(encoderRecord{Name: "LeChuck"})
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+	})
+
+	t.Run("type", func(t *testing.T) {
+		type record struct {
+			Name string
+		}
+
+		encoder := goflat.NewEncoder[record](&tableWriter{}, goflat.Options{ErrorIfTaglessField: true})
+
+		err := encoder.Encode(record{Name: "Guybrush"})
+		if !errors.Is(err, goflat.ErrTaglessField) {
+			t.Errorf("expected %v, got %v", goflat.ErrTaglessField, err)
+		}
+
+		err = encoder.WriteHeader()
+		if !errors.Is(err, goflat.ErrTaglessField) {
+			t.Errorf("expected %v, got %v", goflat.ErrTaglessField, err)
+		}
+	})
+}
@@ -1,6 +1,10 @@
 package goflat
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
 	// ErrNotAStruct is returned when the value to be worked with is not a struct.
@@ -10,13 +14,107 @@ var (
 	ErrTaglessField = errors.New("tagless field")
 	// ErrDuplicatedHeader is returned when there is more than one header with
 	// the same value. Only returned if [Option.ErrorIfDuplicateHeaders] is set
-	// to true.
+	// to true, or when marshalling a value whose extra map contains the header
+	// of another field.
 	ErrDuplicatedHeader = errors.New("duplicated header")
 	// ErrMissingHeader is returned when a header referenced in a "flat" tag
 	// does not appear in the input file. Only returned if
-	// [Option.ErrorIfMissingHeaders] is set to true.
+	// [Option.ErrorIfMissingHeaders] is set to true or the field has the
+	// "required" tag option.
 	ErrMissingHeader = errors.New("missing header")
 	// ErrUnsupportedType is returned when the unmarshaller encounters an
 	// unsupported type.
 	ErrUnsupportedType = errors.New("unsupported type")
+	// ErrInvalidTag is returned when a "flat" tag contains an unknown or
+	// malformed option.
+	ErrInvalidTag = errors.New("invalid tag")
+	// ErrMissingValue is returned when the column of a field with the
+	// "required" tag option is empty.
+	ErrMissingValue = errors.New("missing value")
+	// ErrUnknownColumn is returned when marshalling a value whose extra map
+	// contains a key which is not part of the headers already written.
+	ErrUnknownColumn = errors.New("unknown column")
+	// ErrTooManyErrors is returned when more than [Options.MaxErrors] rows
+	// failed to be unmarshalled.
+	ErrTooManyErrors = errors.New("too many errors")
+	// ErrValueTooLong is returned when writing a fixed-width file and a value
+	// does not fit in the width of its column.
+	ErrValueTooLong = errors.New("value too long")
+	// ErrFixedWidthHeaders is returned when a [FixedWidthReader] or a
+	// [FixedWidthWriter] is used with [Options.Headerless], or when the reader
+	// is wrapped with [WithHeaders]: their header row comes from the tags.
+	ErrFixedWidthHeaders = errors.New("fixed-width headers")
+	// ErrHeaderlessWithHeaders is returned when a reader wrapped with
+	// [WithHeaders] is used with [Options.Headerless].
+	ErrHeaderlessWithHeaders = errors.New("headerless with headers")
+	// ErrTooManyItems is returned when marshalling a value whose repeated
+	// field holds more items than the columns already written.
+	ErrTooManyItems = errors.New("too many items")
 )
+
+// ParseError is returned when a column cannot be unmarshalled into its
+// field. It can be retrieved with [errors.As].
+type ParseError struct {
+	// Line is the line of the column in the file, starting from 1 and
+	// including the headers.
+	Line int
+	// Column is the index of the column in the row.
+	Column int
+	// Header is the header of the column.
+	Header string
+	// Field is the name of the struct field the column maps to. Fields of
+	// nested structs are prefixed with the name of their parents, e.g.
+	// "Address.City".
+	Field string
+	// Value is the raw value of the column.
+	Value string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d %q, field %s: %v", e.Line, e.Column, e.Header, e.Field, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalError is returned when a field cannot be marshalled. It can be
+// retrieved with [errors.As].
+type MarshalError struct {
+	// Row is the index of the value being marshalled, starting from 0.
+	Row int
+	// Field is the name of the struct field which failed, see
+	// [ParseError.Field].
+	Field string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *MarshalError) Error() string {
+	return fmt.Sprintf("row %d, field %s: %v", e.Row, e.Field, e.Err)
+}
+
+func (e *MarshalError) Unwrap() error {
+	return e.Err
+}
+
+// RowErrors holds the errors of all the rows which were skipped because of
+// [Options.CollectErrors]. Each error can be inspected with [errors.As], for
+// instance to retrieve a [*ParseError].
+type RowErrors []error
+
+func (e RowErrors) Error() string {
+	messages := make([]string, 0, len(e))
+
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+func (e RowErrors) Unwrap() []error {
+	return e
+}
@@ -0,0 +1,261 @@
+package goflat
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// expandedField is a map field with the expand tag option, whose pairs are
+// spread across one column per key, e.g. "attr.color" and "attr.size".
+type expandedField struct {
+	// fieldName is the name of the map, as in [columnDescriptor].
+	fieldName   string
+	reflectType reflect.Type
+	// index is the path to the map starting from the root struct.
+	index []int
+	// prefixes are prepended to the keys to name the columns, the first one
+	// is used when marshalling.
+	prefixes []string
+	// key and value convert the keys and the values of the map.
+	key   *columnDescriptor
+	value *columnDescriptor
+}
+
+// expandedBinding maps a header to a key of an expanded map.
+type expandedBinding struct {
+	field int
+	key   reflect.Value
+}
+
+// addExpanded adds an expanded map field, see [expandedField].
+func (s *structInfo) addExpanded(fieldT reflect.StructField, fieldIndex []int, path fieldPath, name string, tagOpts tagOptions) error {
+	t := fieldT.Type
+	if t.Kind() != reflect.Map {
+		return fmt.Errorf("expanded field of type %s is not a map: %w", t, ErrUnsupportedType)
+	}
+
+	if name == "" || strings.Contains(name, repeatedPlaceholder) {
+		return fmt.Errorf("expanded field name %q: %w", name, ErrInvalidTag)
+	}
+
+	for _, prefix := range path.prefixes {
+		if strings.Contains(prefix, repeatedPlaceholder) {
+			return fmt.Errorf("expanded field within repeated field: %w", ErrUnsupportedType)
+		}
+	}
+
+	for _, option := range []string{"index", "pos", "width", "align", "pad", "required", "default", "sep", "kvsep", "brackets", "max"} {
+		if tagOpts.has(option) {
+			return fmt.Errorf("option %q on expanded field: %w", option, ErrInvalidTag)
+		}
+	}
+
+	prefixes := joinNames(path.prefixes, name, nestedSeparator)
+
+	key, err := s.newColumn(prefixes, t.Key(), tagOptions{})
+	if err != nil {
+		return err
+	}
+
+	value, err := s.newColumn(prefixes, t.Elem(), tagOpts)
+	if err != nil {
+		return err
+	}
+
+	s.expanded = append(s.expanded, &expandedField{
+		fieldName:   path.fieldPrefix + fieldT.Name,
+		reflectType: t,
+		index:       fieldIndex,
+		prefixes:    prefixes,
+		key:         key,
+		value:       value,
+	})
+
+	return nil
+}
+
+// bindExpanded maps the headers which are not bound to any column to the
+// keys of the expanded maps, decoding them once. Headers whose key cannot be
+// decoded are left unbound, unless [Options.ErrorIfMissingHeaders] is set.
+func (s *structFactory[T]) bindExpanded(normalizedHeaders []string, covered []bool) error {
+	s.expandedMap = make(map[int]expandedBinding)
+
+	for i, field := range s.expanded {
+		prefixes := field.value.normalizedNames(s.options.HeaderNormalization)
+
+		for j, header := range normalizedHeaders {
+			if covered[j] {
+				continue
+			}
+
+			for _, prefix := range prefixes {
+				keyStr, ok := strings.CutPrefix(header, prefix)
+				if !ok || keyStr == "" {
+					continue
+				}
+
+				key := reflect.New(field.key.reflectType).Elem()
+
+				err := field.key.setValue(key, keyStr)
+				if err != nil {
+					if s.options.ErrorIfMissingHeaders {
+						return fmt.Errorf("header %q of field %s: %w", s.headers[j], field.fieldName, err)
+					}
+
+					continue
+				}
+
+				covered[j] = true
+				s.expandedMap[j] = expandedBinding{field: i, key: key}
+
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// clearExpanded clears the expanded maps, so that they only hold the pairs
+// of the row being unmarshalled.
+func (s *structFactory[T]) clearExpanded(newStruct reflect.Value) {
+	for _, field := range s.expanded {
+		expandedMap := fieldByIndex(newStruct, field.index)
+		if expandedMap.IsValid() {
+			expandedMap.Clear()
+		}
+	}
+}
+
+// setExpanded parses the given column and sets it into its map under the key
+// of the header. Empty columns are skipped, so that the map only holds the
+// keys which have a value.
+func (s *structFactory[T]) setExpanded(newStruct reflect.Value, binding expandedBinding, column string) error {
+	if column == "" {
+		return nil
+	}
+
+	field := s.expanded[binding.field]
+
+	expandedMap := fieldByIndexAlloc(newStruct, field.index)
+	if expandedMap.IsNil() {
+		expandedMap.Set(reflect.MakeMap(field.reflectType))
+	}
+
+	value := reflect.New(field.value.reflectType).Elem()
+
+	if !field.value.nullable || !s.isNil(column) {
+		err := field.value.setValue(value, column)
+		if err != nil {
+			return fmt.Errorf("parse string %q: %w", column, err)
+		}
+	}
+
+	expandedMap.SetMapIndex(binding.key, value)
+
+	return nil
+}
+
+// collectExpandedKeys returns the keys of each expanded map among the given
+// samples, encoded and sorted.
+func (s *structFactory[T]) collectExpandedKeys(samples []T) [][]string {
+	if len(s.expanded) == 0 {
+		return nil
+	}
+
+	keys := make([][]string, len(s.expanded))
+
+	for i, field := range s.expanded {
+		fieldKeys := map[string]struct{}{}
+
+		for _, sample := range samples {
+			expandedMap, err := s.structValue(&sample).FieldByIndexErr(field.index)
+			if err != nil {
+				continue
+			}
+
+			for _, key := range expandedMap.MapKeys() {
+				str, err := field.key.marshalValue(key)
+				if err != nil {
+					// Reported when marshalling the sample.
+					continue
+				}
+
+				fieldKeys[str] = struct{}{}
+			}
+		}
+
+		keys[i] = slices.Sorted(maps.Keys(fieldKeys))
+	}
+
+	return keys
+}
+
+// expandedHeaders appends the headers of the expanded maps, prefixing their
+// keys.
+func (s *structFactory[T]) expandedHeaders(headers []string) []string {
+	for i, field := range s.expanded {
+		for _, key := range s.expandedKeys[i] {
+			headers = append(headers, field.prefixes[0]+key)
+		}
+	}
+
+	return headers
+}
+
+// expandedWidth returns the number of columns of the expanded maps.
+func (s *structFactory[T]) expandedWidth() int {
+	width := 0
+
+	for _, keys := range s.expandedKeys {
+		width += len(keys)
+	}
+
+	return width
+}
+
+// marshalExpanded appends the values of the expanded maps to the record, in
+// the same order as the headers. Missing keys are written as empty columns.
+func (s *structFactory[T]) marshalExpanded(reflectValue reflect.Value, record []string, nilValue string) ([]string, error) {
+	for i, field := range s.expanded {
+		keys := s.expandedKeys[i]
+		start := len(record)
+		record = append(record, make([]string, len(keys))...)
+
+		expandedMap, err := reflectValue.FieldByIndexErr(field.index)
+		if err != nil {
+			continue
+		}
+
+		iter := expandedMap.MapRange()
+		for iter.Next() {
+			key, err := field.key.marshalValue(iter.Key())
+			if err != nil {
+				return nil, &MarshalError{Field: field.fieldName, Err: fmt.Errorf("key: %w", err)}
+			}
+
+			position, found := slices.BinarySearch(keys, key)
+			if !found {
+				return nil, &MarshalError{
+					Field: field.fieldName,
+					Err:   fmt.Errorf("key %q: %w", key, ErrUnknownColumn),
+				}
+			}
+
+			value, err := field.value.marshalField(iter.Value(), nilValue)
+			if err != nil {
+				return nil, &MarshalError{
+					Field: fmt.Sprintf("%s[%s]", field.fieldName, key),
+					Err:   err,
+				}
+			}
+
+			record[start+position] = value
+		}
+	}
+
+	return record, nil
+}
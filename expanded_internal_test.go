@@ -0,0 +1,53 @@
+package goflat
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAddExpandedError(t *testing.T) {
+	tcs := map[string]struct {
+		newFactory func() error
+		expected   error
+	}{
+		"not a map": {
+			newFactory: func() error {
+				_, err := newFactory[struct {
+					Attrs []string `flat:"attr,expand"`
+				}](nil, Options{})
+
+				return err
+			},
+			expected: ErrUnsupportedType,
+		},
+		"no name": {
+			newFactory: func() error {
+				_, err := newFactory[struct {
+					Attrs map[string]string `flat:",expand"`
+				}](nil, Options{})
+
+				return err
+			},
+			expected: ErrInvalidTag,
+		},
+		"packed option": {
+			newFactory: func() error {
+				_, err := newFactory[struct {
+					Attrs map[string]string `flat:"attr,expand,kvsep=:"`
+				}](nil, Options{})
+
+				return err
+			},
+			expected: ErrInvalidTag,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			err := tc.newFactory()
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package goflat
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Alignments accepted by the align tag option.
+const (
+	alignLeft  = "left"
+	alignRight = "right"
+)
+
+// defaultPad is used to pad fixed-width columns when the pad tag option is
+// not set.
+const defaultPad = ' '
+
+// fixedWidthField is the layout of a column in a fixed-width file.
+type fixedWidthField struct {
+	name string
+	// start is the offset of the column in the line, in characters and
+	// starting from 0. It is -1 if the column follows the previous one.
+	start      int
+	width      int
+	rightAlign bool
+	pad        rune
+}
+
+// parseFixedWidth parses the pos, width, align and pad tag options. It
+// returns nil if the field is not part of fixed-width files.
+func parseFixedWidth(tagOpts tagOptions) (*fixedWidthField, error) {
+	widthStr, ok := tagOpts["width"]
+	if !ok {
+		for _, key := range []string{"pos", "align", "pad"} {
+			if tagOpts.has(key) {
+				return nil, fmt.Errorf("option %q without width: %w", key, ErrInvalidTag)
+			}
+		}
+
+		return nil, nil //nolint:nilnil // Not a fixed-width field.
+	}
+
+	width, err := strconv.Atoi(widthStr)
+	if err != nil || width < 1 {
+		return nil, fmt.Errorf("width %q: %w", widthStr, ErrInvalidTag)
+	}
+
+	field := &fixedWidthField{
+		start: -1,
+		width: width,
+		pad:   defaultPad,
+	}
+
+	if posStr, ok := tagOpts["pos"]; ok {
+		pos, err := strconv.Atoi(posStr)
+		if err != nil || pos < 1 {
+			return nil, fmt.Errorf("pos %q: %w", posStr, ErrInvalidTag)
+		}
+
+		field.start = pos - 1
+	}
+
+	switch align := tagOpts["align"]; align {
+	case "", alignLeft:
+	case alignRight:
+		field.rightAlign = true
+	default:
+		return nil, fmt.Errorf("align %q: %w", align, ErrInvalidTag)
+	}
+
+	if pad, ok := tagOpts["pad"]; ok {
+		if utf8.RuneCountInString(pad) != 1 {
+			return nil, fmt.Errorf("pad %q: %w", pad, ErrInvalidTag)
+		}
+
+		field.pad, _ = utf8.DecodeRuneInString(pad)
+	}
+
+	return field, nil
+}
+
+// fixedWidthLayout returns the fixed-width columns of T, sorted by their
+// position in the line. Columns without the pos tag option follow the
+// previous one in declaration order.
+func fixedWidthLayout[T any]() ([]fixedWidthField, error) {
+	factory, err := newFactory[T](nil, Options{headersFromStruct: true})
+	if err != nil {
+		return nil, fmt.Errorf("new factory: %w", err)
+	}
+
+	var (
+		fields []fixedWidthField
+		next   int
+	)
+
+	for _, column := range factory.columns {
+		if column.fixedWidth == nil {
+			continue
+		}
+
+		if column.name == "" {
+			return nil, fmt.Errorf("field %q has no name: %w", column.fieldName, ErrInvalidTag)
+		}
+
+		field := *column.fixedWidth
+		field.name = column.name
+
+		if field.start < 0 {
+			field.start = next
+		}
+
+		next = field.start + field.width
+		fields = append(fields, field)
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("type %s has no fixed-width fields: %w", factory.structType, ErrInvalidTag)
+	}
+
+	slices.SortStableFunc(fields, func(a, b fixedWidthField) int {
+		return cmp.Compare(a.start, b.start)
+	})
+
+	for i := 1; i < len(fields); i++ {
+		if fields[i].start < fields[i-1].start+fields[i-1].width {
+			return nil, fmt.Errorf("columns %q and %q overlap: %w", fields[i-1].name, fields[i].name, ErrInvalidTag)
+		}
+	}
+
+	return fields, nil
+}
+
+// trim removes the padding from a value. A value made only of padding, such
+// as a zero padded with zeros, keeps a single pad character unless the pad
+// is a space.
+func (f fixedWidthField) trim(value string) string {
+	trimmed := strings.TrimRight(value, string(f.pad))
+	if f.rightAlign {
+		trimmed = strings.TrimLeft(value, string(f.pad))
+	}
+
+	if trimmed == "" && value != "" && f.pad != ' ' {
+		return string(f.pad)
+	}
+
+	return trimmed
+}
+
+// align pads a value to the width of the column.
+func (f fixedWidthField) align(value string) (string, error) {
+	length := utf8.RuneCountInString(value)
+	if length > f.width {
+		return "", fmt.Errorf("column %q, value %q longer than %d: %w", f.name, value, f.width, ErrValueTooLong)
+	}
+
+	padding := strings.Repeat(string(f.pad), f.width-length)
+	if f.rightAlign {
+		return padding + value, nil
+	}
+
+	return value + padding, nil
+}
+
+// FixedWidthReader reads a fixed-width file, where each column spans the
+// characters described by the pos and width tag options of T. Its first row
+// holds the names of the columns, so that the file is unmarshalled as if it
+// had headers: [Options.Headerless] and [WithHeaders] cause
+// [ErrFixedWidthHeaders].
+//
+// Empty lines are skipped and lines shorter than the layout leave the
+// missing columns empty.
+type FixedWidthReader struct {
+	reader      *bufio.Reader
+	fields      []fixedWidthField
+	headersRead bool
+	line        int
+}
+
+// NewFixedWidthReader returns a reader of fixed-width files laid out
+// according to the tags of T.
+func NewFixedWidthReader[T any](reader io.Reader) (*FixedWidthReader, error) {
+	fields, err := fixedWidthLayout[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	return &FixedWidthReader{
+		reader: bufio.NewReader(reader),
+		fields: fields,
+	}, nil
+}
+
+// Read returns the next row, or [io.EOF] once the file is over.
+func (r *FixedWidthReader) Read() ([]string, error) {
+	if !r.headersRead {
+		r.headersRead = true
+
+		headers := make([]string, len(r.fields))
+		for i, field := range r.fields {
+			headers[i] = field.name
+		}
+
+		return headers, nil
+	}
+
+	for {
+		line, err := r.reader.ReadString('\n')
+		if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+			return nil, err //nolint:wrapcheck // io.EOF must not be wrapped.
+		}
+
+		r.line++
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			continue
+		}
+
+		return r.split(line), nil
+	}
+}
+
+// FieldPos returns the line and the column, starting from 1, of the given
+// field in the last row read.
+func (r *FixedWidthReader) FieldPos(field int) (int, int) {
+	if field < 0 || field >= len(r.fields) {
+		return r.line, 0
+	}
+
+	return r.line, r.fields[field].start + 1
+}
+
+func (r *FixedWidthReader) split(line string) []string {
+	runes := []rune(line)
+	record := make([]string, len(r.fields))
+
+	for i, field := range r.fields {
+		start := min(field.start, len(runes))
+		end := min(field.start+field.width, len(runes))
+		record[i] = field.trim(string(runes[start:end]))
+	}
+
+	return record
+}
+
+// FixedWidthWriter writes a fixed-width file, where each column spans the
+// characters described by the pos and width tag options of T. The first row
+// it is given must hold the headers: they are used to map the cells of the
+// following rows to the columns and they are not written. Cells whose header
+// has no fixed-width layout are dropped. [Options.Headerless] causes
+// [ErrFixedWidthHeaders].
+type FixedWidthWriter struct {
+	// UseCRLF ends each line with \r\n instead of \n.
+	UseCRLF bool
+	writer  *bufio.Writer
+	fields  []fixedWidthField
+	// columns maps each cell of a row to the index of its field, or -1.
+	columns []int
+}
+
+// NewFixedWidthWriter returns a writer of fixed-width files laid out
+// according to the tags of T.
+func NewFixedWidthWriter[T any](writer io.Writer) (*FixedWidthWriter, error) {
+	fields, err := fixedWidthLayout[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	return &FixedWidthWriter{
+		writer: bufio.NewWriter(writer),
+		fields: fields,
+	}, nil
+}
+
+// Write writes a row. Writes are buffered, so [FixedWidthWriter.Flush] must
+// be called to ensure that the row is written to the underlying writer.
+func (w *FixedWidthWriter) Write(record []string) error {
+	if w.columns == nil {
+		w.bindHeaders(record)
+
+		return nil
+	}
+
+	cells := make([]string, len(w.fields))
+
+	for i, value := range record {
+		if i < len(w.columns) && w.columns[i] >= 0 {
+			cells[w.columns[i]] = value
+		}
+	}
+
+	var (
+		line    strings.Builder
+		written int
+	)
+
+	for i, field := range w.fields {
+		line.WriteString(strings.Repeat(" ", field.start-written))
+
+		cell, err := field.align(cells[i])
+		if err != nil {
+			return err
+		}
+
+		line.WriteString(cell)
+
+		written = field.start + field.width
+	}
+
+	if w.UseCRLF {
+		line.WriteString("\r\n")
+	} else {
+		line.WriteString("\n")
+	}
+
+	_, err := w.writer.WriteString(line.String())
+
+	return err //nolint:wrapcheck // Same as the underlying writer.
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *FixedWidthWriter) Flush() error {
+	return w.writer.Flush() //nolint:wrapcheck // Same as the underlying writer.
+}
+
+func (w *FixedWidthWriter) bindHeaders(headers []string) {
+	w.columns = make([]int, len(headers))
+
+	for i, header := range headers {
+		w.columns[i] = slices.IndexFunc(w.fields, func(field fixedWidthField) bool {
+			return field.name == header
+		})
+	}
+}
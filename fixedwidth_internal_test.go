@@ -0,0 +1,65 @@
+package goflat
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseFixedWidth(t *testing.T) {
+	t.Run("error", testParseFixedWidthError)
+	t.Run("success", testParseFixedWidthSuccess)
+}
+
+func testParseFixedWidthError(t *testing.T) {
+	tcs := map[string]tagOptions{
+		"pos without width": {"pos": "1"},
+		"zero width":        {"width": "0"},
+		"zero pos":          {"pos": "0", "width": "1"},
+		"unknown align":     {"width": "1", "align": "center"},
+		"long pad":          {"width": "1", "pad": "00"},
+	}
+
+	for name, tagOpts := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseFixedWidth(tagOpts)
+			if !errors.Is(err, ErrInvalidTag) {
+				t.Errorf("expected %v, got %v", ErrInvalidTag, err)
+			}
+		})
+	}
+}
+
+func testParseFixedWidthSuccess(t *testing.T) {
+	tcs := map[string]struct {
+		tagOpts  tagOptions
+		expected *fixedWidthField
+	}{
+		"none": {
+			tagOpts:  tagOptions{},
+			expected: nil,
+		},
+		"width only": {
+			tagOpts:  tagOptions{"width": "4"},
+			expected: &fixedWidthField{start: -1, width: 4, pad: ' '},
+		},
+		"full": {
+			tagOpts:  tagOptions{"pos": "3", "width": "4", "align": "right", "pad": "0"},
+			expected: &fixedWidthField{start: 2, width: 4, rightAlign: true, pad: '0'},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseFixedWidth(tc.tagOpts)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expected, got, cmp.AllowUnexported(fixedWidthField{})); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package goflat_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/lzambarda/goflat"
+)
+
+func TestFixedWidth(t *testing.T) {
+	t.Run("unmarshal", testFixedWidthUnmarshal)
+	t.Run("unmarshal error", testFixedWidthUnmarshalError)
+	t.Run("marshal", testFixedWidthMarshal)
+	t.Run("marshal error", testFixedWidthMarshalError)
+}
+
+type payment struct {
+	Account string  `flat:"account,pos=1,width=10,align=right,pad=0"`
+	Name    string  `flat:"name,width=12"`
+	Amount  float64 `flat:"amount,pos=25,width=8,align=right"`
+	Note    string  `flat:"note"`
+}
+
+func testFixedWidthUnmarshal(t *testing.T) {
+	input := "0000012345Guybrush        1234.5\r\n" +
+		"\n" +
+		"0000000000Elaine           99.99\n" +
+		"0000000042LeChuck\n"
+
+	expected := []payment{
+		{Account: "12345", Name: "Guybrush", Amount: 1234.5},
+		{Account: "0", Name: "Elaine", Amount: 99.99},
+		{Account: "42", Name: "LeChuck"},
+	}
+
+	reader, err := goflat.NewFixedWidthReader[payment](strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("new reader: %v", err)
+	}
+
+	got, err := goflat.UnmarshalToSlice[payment](t.Context(), reader, goflat.Options{UnmarshalIgnoreEmpty: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func testFixedWidthUnmarshalError(t *testing.T) {
+	t.Run("layout", func(t *testing.T) {
+		type overlapping struct {
+			First  string `flat:"first,pos=1,width=5"`
+			Second string `flat:"second,pos=3,width=5"`
+		}
+
+		_, err := goflat.NewFixedWidthReader[overlapping](strings.NewReader(""))
+		if !errors.Is(err, goflat.ErrInvalidTag) {
+			t.Errorf("expected %v, got %v", goflat.ErrInvalidTag, err)
+		}
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		input := "0000012345Guybrush        1234.5\n" +
+			"0000012346Elaine            lots\n"
+
+		reader, err := goflat.NewFixedWidthReader[payment](strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("new reader: %v", err)
+		}
+
+		_, err = goflat.UnmarshalToSlice[payment](t.Context(), reader, goflat.Options{})
+
+		var parseErr *goflat.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("expected %T, got %v", parseErr, err)
+		}
+
+		if parseErr.Line != 2 {
+			t.Errorf("expected line 2, got %d", parseErr.Line)
+		}
+
+		if parseErr.Field != "Amount" {
+			t.Errorf("expected field %q, got %q", "Amount", parseErr.Field)
+		}
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		tcs := map[string]struct {
+			wrap    func(*goflat.FixedWidthReader) goflat.RowReader
+			options goflat.Options
+		}{
+			"headerless": {
+				wrap:    func(reader *goflat.FixedWidthReader) goflat.RowReader { return reader },
+				options: goflat.Options{Headerless: true},
+			},
+			"with headers": {
+				wrap: func(reader *goflat.FixedWidthReader) goflat.RowReader {
+					return goflat.WithHeaders(reader, "account", "name", "amount")
+				},
+			},
+		}
+
+		for name, tc := range tcs {
+			t.Run(name, func(t *testing.T) {
+				reader, err := goflat.NewFixedWidthReader[payment](strings.NewReader("0000012345Guybrush        1234.5\n"))
+				if err != nil {
+					t.Fatalf("new reader: %v", err)
+				}
+
+				_, err = goflat.UnmarshalToSlice[payment](t.Context(), tc.wrap(reader), tc.options)
+				if !errors.Is(err, goflat.ErrFixedWidthHeaders) {
+					t.Errorf("expected %v, got %v", goflat.ErrFixedWidthHeaders, err)
+				}
+			})
+		}
+	})
+}
+
+func testFixedWidthMarshal(t *testing.T) {
+	input := []payment{
+		{Account: "12345", Name: "Guybrush", Amount: 1234.5, Note: "dropped"},
+		{Account: "42", Name: "Elaine", Amount: 99.99},
+	}
+
+	expected := "0000012345Guybrush        1234.5\n" +
+		"0000000042Elaine           99.99\n"
+
+	var got bytes.Buffer
+
+	writer, err := goflat.NewFixedWidthWriter[payment](&got)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+
+	err = goflat.MarshalSliceToWriter(t.Context(), input, writer, goflat.Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if diff := cmp.Diff(expected, got.String()); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func testFixedWidthMarshalError(t *testing.T) {
+	input := []payment{
+		{Account: "12345678901", Name: "Guybrush"},
+	}
+
+	writer, err := goflat.NewFixedWidthWriter[payment](&bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+
+	err = goflat.MarshalSliceToWriter(t.Context(), input, writer, goflat.Options{})
+	if !errors.Is(err, goflat.ErrValueTooLong) {
+		t.Errorf("expected %v, got %v", goflat.ErrValueTooLong, err)
+	}
+
+	err = goflat.MarshalSliceToWriter(t.Context(), input, writer, goflat.Options{Headerless: true})
+	if !errors.Is(err, goflat.ErrFixedWidthHeaders) {
+		t.Errorf("expected %v, got %v", goflat.ErrFixedWidthHeaders, err)
+	}
+}
@@ -0,0 +1,120 @@
+package goflat
+
+import (
+	"reflect"
+	"slices"
+)
+
+// FlatMarshaller is implemented by the code generated by goflat-gen, so that
+// the fields of a struct can be read without reflection. It is only used if
+// the columns it lists match the ones goflat collects from the struct, which
+// protects against stale generated code.
+type FlatMarshaller interface {
+	// FlatColumns returns the Go names of the fields mapped to columns, in
+	// declaration order. Fields of nested structs are prefixed with the name
+	// of their parents, e.g. "Address.City".
+	FlatColumns() []string
+	// MarshalFlat returns a pointer to the value of the given column or, for
+	// pointer fields, the field itself. It returns nil if a nested struct
+	// containing the field is a nil pointer.
+	MarshalFlat(column int) any
+}
+
+// FlatUnmarshaller is implemented by the code generated by goflat-gen, so
+// that the fields of a struct can be set without reflection. It is only used
+// if the columns it lists match the ones goflat collects from the struct,
+// which protects against stale generated code.
+type FlatUnmarshaller interface {
+	// FlatColumns works like [FlatMarshaller.FlatColumns].
+	FlatColumns() []string
+	// UnmarshalFlat returns a pointer to the value of the given column,
+	// allocating the field and any nested struct containing it if they are
+	// nil pointers.
+	UnmarshalFlat(column int) any
+}
+
+//nolint:gochecknoglobals // Used for type checking.
+var (
+	flatMarshallerType   = reflect.TypeFor[FlatMarshaller]()
+	flatUnmarshallerType = reflect.TypeFor[FlatUnmarshaller]()
+)
+
+// hasGeneratedCode returns whether a pointer to the given struct type
+// implements the interface, with columns matching the given ones.
+func hasGeneratedCode(t, interfaceType reflect.Type, columns []*columnDescriptor) bool {
+	if !reflect.PointerTo(t).Implements(interfaceType) {
+		return false
+	}
+
+	//nolint:forcetypeassert // Checked above.
+	flatColumns := reflect.New(t).Interface().(interface{ FlatColumns() []string }).FlatColumns()
+
+	return slices.EqualFunc(flatColumns, columns, func(name string, column *columnDescriptor) bool {
+		return name == column.fieldName
+	})
+}
+
+// flatMarshaller returns the generated code of the given value, if any.
+//
+//nolint:ireturn // Fine here.
+func (s *structFactory[T]) flatMarshaller(t *T) FlatMarshaller {
+	if !s.flatMarshal {
+		return nil
+	}
+
+	if s.pointer {
+		return any(*t).(FlatMarshaller) //nolint:forcetypeassert // Checked by hasGeneratedCode.
+	}
+
+	return any(t).(FlatMarshaller) //nolint:forcetypeassert // Checked by hasGeneratedCode.
+}
+
+// flatUnmarshaller returns the generated code of the given addressable
+// struct, if any.
+//
+//nolint:ireturn // Fine here.
+func (s *structFactory[T]) flatUnmarshaller(newStruct reflect.Value) FlatUnmarshaller {
+	if !s.flatUnmarshal {
+		return nil
+	}
+
+	return newStruct.Addr().Interface().(FlatUnmarshaller) //nolint:forcetypeassert // Checked by hasGeneratedCode.
+}
+
+// marshalFlatColumn works like [structFactory.marshalColumn] but uses the
+// generated code to read the field.
+func (s *structFactory[T]) marshalFlatColumn(flat FlatMarshaller, i int, nilValue string) (string, error) {
+	column := s.columns[i]
+
+	ptr := flat.MarshalFlat(i)
+	if ptr == nil {
+		// The generated code returns nil for fields under a nil struct pointer.
+		return nilValue, nil
+	}
+
+	switch column.reflectType.Kind() { //nolint:exhaustive // Fine here, there's a default.
+	case reflect.Pointer:
+		if reflect.ValueOf(ptr).IsNil() {
+			if column.omitEmpty {
+				return "", nil
+			}
+
+			return nilValue, nil
+		}
+	case reflect.Slice, reflect.Map:
+		value := reflect.ValueOf(ptr).Elem()
+		if column.omitEmpty && value.IsZero() {
+			return "", nil
+		}
+
+		if value.IsNil() {
+			return nilValue, nil
+		}
+	default:
+		if column.omitEmpty && reflect.ValueOf(ptr).Elem().IsZero() {
+			return "", nil
+		}
+	}
+
+	return column.encodePtr(ptr)
+}
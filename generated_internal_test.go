@@ -0,0 +1,110 @@
+package goflat
+
+import (
+	"testing"
+)
+
+type flatRecord struct {
+	Name string `flat:"name"`
+	Age  *int   `flat:"age"`
+}
+
+func (*flatRecord) FlatColumns() []string {
+	return []string{"Name", "Age"}
+}
+
+func (v *flatRecord) MarshalFlat(column int) any {
+	switch column {
+	case 0:
+		return &v.Name
+	case 1:
+		return v.Age
+	}
+
+	return nil
+}
+
+func (v *flatRecord) UnmarshalFlat(column int) any {
+	switch column {
+	case 0:
+		return &v.Name
+	case 1:
+		v.Age = new(int)
+
+		return v.Age
+	}
+
+	return nil
+}
+
+// staleRecord has generated code which no longer matches its fields.
+type staleRecord struct {
+	Name string `flat:"name"`
+	Age  int    `flat:"age"`
+}
+
+func (*staleRecord) FlatColumns() []string {
+	return []string{"Name"}
+}
+
+func (v *staleRecord) MarshalFlat(int) any {
+	return &v.Name
+}
+
+func (v *staleRecord) UnmarshalFlat(int) any {
+	return &v.Name
+}
+
+func TestGeneratedCode(t *testing.T) {
+	t.Run("detected", testGeneratedCodeDetected)
+	t.Run("stale", testGeneratedCodeStale)
+}
+
+func testGeneratedCodeDetected(t *testing.T) {
+	factory, err := newFactory[flatRecord]([]string{"name", "age"}, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !factory.flatMarshal || !factory.flatUnmarshal {
+		t.Fatalf("expected generated code to be detected")
+	}
+
+	got, err := factory.unmarshal([]string{"Guybrush", "28"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got.Name != "Guybrush" || got.Age == nil || *got.Age != 28 {
+		t.Errorf("unexpected value %+v", got)
+	}
+
+	record, err := factory.marshal(&flatRecord{Name: "Elaine"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if record[0] != "Elaine" || record[1] != defaultNilValue {
+		t.Errorf("unexpected record %q", record)
+	}
+}
+
+func testGeneratedCodeStale(t *testing.T) {
+	factory, err := newFactory[staleRecord]([]string{"name", "age"}, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if factory.flatMarshal || factory.flatUnmarshal {
+		t.Fatalf("expected stale generated code to be ignored")
+	}
+
+	got, err := factory.unmarshal([]string{"Guybrush", "28"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got.Name != "Guybrush" || got.Age != 28 {
+		t.Errorf("unexpected value %+v", got)
+	}
+}
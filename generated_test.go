@@ -0,0 +1,227 @@
+package goflat_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/lzambarda/goflat"
+)
+
+//go:generate go run ./cmd/goflat-gen -type generatedRecord,benchGeneratedRecord
+
+type generatedBase struct {
+	ID int64 `flat:"id"`
+}
+
+type generatedAddress struct {
+	City string  `flat:"city"`
+	Zip  *string `flat:"zip"`
+}
+
+type generatedRecord struct {
+	generatedBase
+
+	Name     string            `flat:"name,required"`
+	Age      int               `flat:"age,default=18"`
+	Height   *float64          `flat:"height"`
+	Active   bool              `flat:"active,omitempty"`
+	Level    uint8             `flat:"level|lvl"`
+	Created  time.Time         `flat:"created,layout=2006-01-02"`
+	Updated  *time.Time        `flat:"updated"`
+	Timeout  time.Duration     `flat:"timeout"`
+	Addr     netip.Addr        `flat:"addr"`
+	Tags     []int64           `flat:"tags"`
+	Attrs    map[string]int    `flat:"attrs"`
+	Payload  map[string]string `flat:"payload,json"`
+	Address  *generatedAddress `flat:"address"`
+	Extra    map[string]string `flat:",extra"`
+	Ignored  string            `flat:"-"`
+	Untagged string
+}
+
+// reflectedRecord has the same fields as generatedRecord but none of its
+// methods, so it goes through reflection.
+type reflectedRecord generatedRecord
+
+func TestGenerated(t *testing.T) {
+	t.Run("unmarshal", testGeneratedUnmarshal)
+	t.Run("marshal", testGeneratedMarshal)
+	t.Run("preserve unset fields", testGeneratedPreserveUnsetFields)
+}
+
+//nolint:gochecknoglobals // Shared by the tests.
+var generatedOptions = map[string]goflat.Options{
+	"default":        {},
+	"ignore empty":   {UnmarshalIgnoreEmpty: true},
+	"empty as nil":   {EmptyAsNil: true},
+	"nil value":      {NilValue: "NULL"},
+	"time layout":    {TimeLayout: time.RFC1123},
+	"missing header": {ErrorIfMissingHeaders: true},
+	"normalization":  {HeaderNormalization: goflat.NormalizeAll},
+	"preserve":       {PreserveUnsetFields: true},
+}
+
+func testGeneratedUnmarshal(t *testing.T) {
+	inputs := map[string]string{
+		"full": `id,name,age,height,active,lvl,created,updated,timeout,addr,tags,attrs,payload,address.city,address.zip,other
+1,Guybrush,28,1.8,true,3,2024-01-02,2024-01-02T10:00:00Z,1m,127.0.0.1,"1,2","a=1,b=2","{""k"":""v""}",Melee,,x
+2,Elaine,,nil,false,,2024-01-03,nil,2s,::1,3,nil,nil,nil,nil,
+3,LeChuck,,,,,2024-01-04,,,::1,4,,{},,NULL,y
+`,
+		"partial": `name,Age,Address.City
+Guybrush,28,Melee
+Elaine,,
+`,
+		"errors": `name,age,created,addr
+Guybrush,old,2024-01-02,::1
+,28,2024-01-02,::1
+Elaine,20,yesterday,::1
+LeChuck,30,2024-01-02,nowhere
+`,
+	}
+
+	for inputName, input := range inputs {
+		for optionsName, options := range generatedOptions {
+			t.Run(inputName+"/"+optionsName, func(t *testing.T) {
+				options.CollectErrors = true
+
+				generated, generatedErr := goflat.UnmarshalToSlice[generatedRecord](t.Context(), csv.NewReader(strings.NewReader(input)), options)
+				reflected, reflectedErr := goflat.UnmarshalToSlice[*reflectedRecord](t.Context(), csv.NewReader(strings.NewReader(input)), options)
+
+				if diff := cmp.Diff(errorString(reflectedErr), errorString(generatedErr)); diff != "" {
+					t.Errorf("errors (-reflected +generated):\n%s", diff)
+				}
+
+				var converted []generatedRecord
+				for _, record := range reflected {
+					converted = append(converted, generatedRecord(*record))
+				}
+
+				if diff := cmp.Diff(converted, generated, cmp.AllowUnexported(generatedRecord{}), cmp.Comparer(func(a, b netip.Addr) bool {
+					return a == b
+				})); diff != "" {
+					t.Errorf("(-reflected +generated):\n%s", diff)
+				}
+			})
+		}
+	}
+}
+
+func testGeneratedMarshal(t *testing.T) {
+	height := 1.8
+	updated := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	zip := "12345"
+
+	input := []generatedRecord{
+		{
+			generatedBase: generatedBase{ID: 1},
+			Name:          "Guybrush",
+			Age:           28,
+			Height:        &height,
+			Active:        true,
+			Level:         3,
+			Created:       time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			Updated:       &updated,
+			Timeout:       time.Minute,
+			Addr:          netip.MustParseAddr("127.0.0.1"),
+			Tags:          []int64{1, 2},
+			Attrs:         map[string]int{"a": 1, "b": 2},
+			Payload:       map[string]string{"k": "v"},
+			Address:       &generatedAddress{City: "Melee", Zip: &zip},
+			Extra:         map[string]string{"other": "x"},
+		},
+		{
+			Name:    "Elaine",
+			Attrs:   map[string]int{},
+			Payload: map[string]string{},
+			Address: &generatedAddress{},
+		},
+		{
+			Name: "LeChuck",
+		},
+	}
+
+	reflected := make([]reflectedRecord, len(input))
+	for i, record := range input {
+		reflected[i] = reflectedRecord(record)
+	}
+
+	for optionsName, options := range generatedOptions {
+		t.Run(optionsName, func(t *testing.T) {
+			var generatedOut, reflectedOut bytes.Buffer
+
+			generatedErr := goflat.MarshalSliceToWriter(t.Context(), input, csv.NewWriter(&generatedOut), options)
+			reflectedErr := goflat.MarshalSliceToWriter(t.Context(), reflected, csv.NewWriter(&reflectedOut), options)
+
+			if diff := cmp.Diff(errorString(reflectedErr), errorString(generatedErr)); diff != "" {
+				t.Errorf("errors (-reflected +generated):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(reflectedOut.String(), generatedOut.String()); diff != "" {
+				t.Errorf("(-reflected +generated):\n%s", diff)
+			}
+		})
+	}
+}
+
+// testGeneratedPreserveUnsetFields decodes every row into the same value, so
+// that the non-nil pointer fields are reused.
+func testGeneratedPreserveUnsetFields(t *testing.T) {
+	input := `name,height,address.zip
+Guybrush,1.8,12345
+Elaine,1.7,
+`
+
+	options := goflat.Options{PreserveUnsetFields: true}
+
+	generatedDecoder := goflat.NewDecoder[generatedRecord](csv.NewReader(strings.NewReader(input)), options)
+	reflectedDecoder := goflat.NewDecoder[reflectedRecord](csv.NewReader(strings.NewReader(input)), options)
+
+	var (
+		generated       generatedRecord
+		reflected       reflectedRecord
+		generatedHeight *float64
+		reflectedHeight *float64
+	)
+
+	for row := 0; ; row++ {
+		generatedErr := generatedDecoder.Decode(&generated)
+		reflectedErr := reflectedDecoder.Decode(&reflected)
+
+		if diff := cmp.Diff(errorString(reflectedErr), errorString(generatedErr)); diff != "" {
+			t.Fatalf("row %d, errors (-reflected +generated):\n%s", row, diff)
+		}
+
+		if errors.Is(generatedErr, io.EOF) {
+			return
+		}
+
+		if diff := cmp.Diff(generatedRecord(reflected), generated, cmp.AllowUnexported(generatedRecord{}), cmp.Comparer(func(a, b netip.Addr) bool {
+			return a == b
+		})); diff != "" {
+			t.Errorf("row %d (-reflected +generated):\n%s", row, diff)
+		}
+
+		if row > 0 && (generated.Height != generatedHeight || reflected.Height != reflectedHeight) {
+			t.Errorf("row %d: expected the height pointer to be reused", row)
+		}
+
+		generatedHeight, reflectedHeight = generated.Height, reflected.Height
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,198 @@
+// Code generated by goflat-gen. DO NOT EDIT.
+
+package goflat_test
+
+import (
+	"time"
+)
+
+// FlatColumns implements goflat.FlatMarshaller and goflat.FlatUnmarshaller.
+func (*generatedRecord) FlatColumns() []string {
+	return []string{
+		"generatedBase.ID",
+		"Name",
+		"Age",
+		"Height",
+		"Active",
+		"Level",
+		"Created",
+		"Updated",
+		"Timeout",
+		"Addr",
+		"Tags",
+		"Attrs",
+		"Payload",
+		"Address.City",
+		"Address.Zip",
+	}
+}
+
+// MarshalFlat implements goflat.FlatMarshaller.
+func (v *generatedRecord) MarshalFlat(column int) any {
+	switch column {
+	case 0:
+		return &v.generatedBase.ID
+	case 1:
+		return &v.Name
+	case 2:
+		return &v.Age
+	case 3:
+		return v.Height
+	case 4:
+		return &v.Active
+	case 5:
+		return &v.Level
+	case 6:
+		return &v.Created
+	case 7:
+		return v.Updated
+	case 8:
+		return &v.Timeout
+	case 9:
+		return &v.Addr
+	case 10:
+		return &v.Tags
+	case 11:
+		return &v.Attrs
+	case 12:
+		return &v.Payload
+	case 13:
+		if v.Address == nil {
+			return nil
+		}
+
+		return &v.Address.City
+	case 14:
+		if v.Address == nil {
+			return nil
+		}
+
+		return v.Address.Zip
+	}
+
+	return nil
+}
+
+// UnmarshalFlat implements goflat.FlatUnmarshaller.
+func (v *generatedRecord) UnmarshalFlat(column int) any {
+	switch column {
+	case 0:
+		return &v.generatedBase.ID
+	case 1:
+		return &v.Name
+	case 2:
+		return &v.Age
+	case 3:
+		if v.Height == nil {
+			v.Height = new(float64)
+		}
+
+		return v.Height
+	case 4:
+		return &v.Active
+	case 5:
+		return &v.Level
+	case 6:
+		return &v.Created
+	case 7:
+		if v.Updated == nil {
+			v.Updated = new(time.Time)
+		}
+
+		return v.Updated
+	case 8:
+		return &v.Timeout
+	case 9:
+		return &v.Addr
+	case 10:
+		return &v.Tags
+	case 11:
+		return &v.Attrs
+	case 12:
+		return &v.Payload
+	case 13:
+		if v.Address == nil {
+			v.Address = new(generatedAddress)
+		}
+
+		return &v.Address.City
+	case 14:
+		if v.Address == nil {
+			v.Address = new(generatedAddress)
+		}
+
+		if v.Address.Zip == nil {
+			v.Address.Zip = new(string)
+		}
+
+		return v.Address.Zip
+	}
+
+	return nil
+}
+
+// FlatColumns implements goflat.FlatMarshaller and goflat.FlatUnmarshaller.
+func (*benchGeneratedRecord) FlatColumns() []string {
+	return []string{
+		"Name",
+		"Age",
+		"Height",
+		"Active",
+		"Comment",
+		"Level",
+		"CreatedAt",
+		"Timeout",
+	}
+}
+
+// MarshalFlat implements goflat.FlatMarshaller.
+func (v *benchGeneratedRecord) MarshalFlat(column int) any {
+	switch column {
+	case 0:
+		return &v.Name
+	case 1:
+		return &v.Age
+	case 2:
+		return &v.Height
+	case 3:
+		return &v.Active
+	case 4:
+		return v.Comment
+	case 5:
+		return &v.Level
+	case 6:
+		return &v.CreatedAt
+	case 7:
+		return &v.Timeout
+	}
+
+	return nil
+}
+
+// UnmarshalFlat implements goflat.FlatUnmarshaller.
+func (v *benchGeneratedRecord) UnmarshalFlat(column int) any {
+	switch column {
+	case 0:
+		return &v.Name
+	case 1:
+		return &v.Age
+	case 2:
+		return &v.Height
+	case 3:
+		return &v.Active
+	case 4:
+		if v.Comment == nil {
+			v.Comment = new(string)
+		}
+
+		return v.Comment
+	case 5:
+		return &v.Level
+	case 6:
+		return &v.CreatedAt
+	case 7:
+		return &v.Timeout
+	}
+
+	return nil
+}
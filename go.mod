@@ -2,7 +2,4 @@ module github.com/lzambarda/goflat
 
 go 1.26
 
-require (
-	github.com/google/go-cmp v0.6.0
-	golang.org/x/sync v0.9.0
-)
+require github.com/google/go-cmp v0.6.0
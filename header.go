@@ -0,0 +1,82 @@
+package goflat
+
+import "strings"
+
+// HeaderNormalization is a set of transformations applied both to the headers
+// of a file and to the names in the `flat` tags before matching them. Values
+// can be combined with a bitwise OR.
+type HeaderNormalization uint8
+
+const (
+	// NormalizeBOM strips the UTF-8 byte order mark from the first header.
+	NormalizeBOM HeaderNormalization = 1 << iota
+	// NormalizeTrim removes leading and trailing whitespace.
+	NormalizeTrim
+	// NormalizeCase folds headers to lower case.
+	NormalizeCase
+	// NormalizeSeparators treats spaces and underscores as the same
+	// separator, collapsing consecutive ones into a single underscore.
+	NormalizeSeparators
+	// NormalizeAll enables all the normalizations.
+	NormalizeAll = NormalizeBOM | NormalizeTrim | NormalizeCase | NormalizeSeparators
+)
+
+// aliasSeparator separates the alternative names of a column in a `flat`
+// tag, e.g. `flat:"first_name|firstname"`.
+const aliasSeparator = "|"
+
+const byteOrderMark = "\uFEFF"
+
+// normalizeHeaders returns a normalized copy of the given headers.
+func normalizeHeaders(headers []string, normalization HeaderNormalization) []string {
+	normalized := make([]string, len(headers))
+
+	for i, header := range headers {
+		if i == 0 && normalization&NormalizeBOM != 0 {
+			header = strings.TrimPrefix(header, byteOrderMark)
+		}
+
+		normalized[i] = normalizeHeader(header, normalization)
+	}
+
+	return normalized
+}
+
+func normalizeHeader(header string, normalization HeaderNormalization) string {
+	if normalization&NormalizeTrim != 0 {
+		header = strings.TrimSpace(header)
+	}
+
+	if normalization&NormalizeCase != 0 {
+		header = strings.ToLower(header)
+	}
+
+	if normalization&NormalizeSeparators != 0 {
+		header = collapseSeparators(header)
+	}
+
+	return header
+}
+
+func collapseSeparators(header string) string {
+	var builder strings.Builder
+
+	builder.Grow(len(header))
+
+	previousSeparator := false
+
+	for _, r := range header {
+		isSeparator := r == ' ' || r == '_'
+		if isSeparator {
+			if !previousSeparator {
+				builder.WriteByte('_')
+			}
+		} else {
+			builder.WriteRune(r)
+		}
+
+		previousSeparator = isSeparator
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,53 @@
+package goflat
+
+import "testing"
+
+func TestNormalizeHeader(t *testing.T) {
+	tcs := map[string]struct {
+		header        string
+		normalization HeaderNormalization
+		expected      string
+	}{
+		"none": {
+			header:        " First  Name ",
+			normalization: 0,
+			expected:      " First  Name ",
+		},
+		"trim": {
+			header:        " First  Name ",
+			normalization: NormalizeTrim,
+			expected:      "First  Name",
+		},
+		"case": {
+			header:        "FIRST_NAME",
+			normalization: NormalizeCase,
+			expected:      "first_name",
+		},
+		"separators": {
+			header:        "First _ Name",
+			normalization: NormalizeSeparators,
+			expected:      "First_Name",
+		},
+		"all": {
+			header:        " First  Name ",
+			normalization: NormalizeAll,
+			expected:      "first_name",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got := normalizeHeader(tc.header, tc.normalization)
+			if got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+
+	t.Run("bom", func(t *testing.T) {
+		got := normalizeHeaders([]string{"\uFEFFname", "\uFEFFage"}, NormalizeBOM)
+		if got[0] != "name" || got[1] != "\uFEFFage" {
+			t.Errorf("expected only the first header to be stripped, got %q", got)
+		}
+	})
+}
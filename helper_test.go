@@ -2,6 +2,7 @@ package goflat_test
 
 import (
 	"context"
+	"io"
 	"testing"
 	"time"
 
@@ -44,3 +45,38 @@ func assertChannel[T any](t *testing.T, ch <-chan T, expected []T, cmpOpts ...cm
 		}
 	})
 }
+
+// tableReader is an in-memory [goflat.RowReader].
+type tableReader struct {
+	rows [][]string
+}
+
+func (r *tableReader) Read() ([]string, error) {
+	if len(r.rows) == 0 {
+		return nil, io.EOF
+	}
+
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+
+	return row, nil
+}
+
+// tableWriter is an in-memory [goflat.RowWriter] which must be flushed.
+type tableWriter struct {
+	rows    [][]string
+	pending [][]string
+}
+
+func (w *tableWriter) Write(record []string) error {
+	w.pending = append(w.pending, record)
+
+	return nil
+}
+
+func (w *tableWriter) Flush() error {
+	w.rows = append(w.rows, w.pending...)
+	w.pending = nil
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package goflat
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Defaults of the sep and kvsep tag options of map fields.
+const (
+	defaultMapSep   = ";"
+	defaultMapKVSep = "="
+)
+
+// mapFormat is the format of the cells of map fields: the pairs are joined by
+// sep, while the key and the value of each pair are joined by kvSep.
+type mapFormat struct {
+	sep   string
+	kvSep string
+}
+
+// parseMapFormat parses the sep and kvsep tag options. The kvsep option is
+// only valid for maps.
+func parseMapFormat(tagOpts tagOptions, t reflect.Type) (mapFormat, error) {
+	format := mapFormat{sep: defaultMapSep, kvSep: defaultMapKVSep}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Map {
+		if tagOpts.has("kvsep") {
+			return format, fmt.Errorf("kvsep option on type %s: %w", t, ErrInvalidTag)
+		}
+
+		return format, nil
+	}
+
+	if tagOpts.has("brackets") {
+		return format, fmt.Errorf("brackets option on type %s: %w", t, ErrInvalidTag)
+	}
+
+	if sep, ok := tagOpts["sep"]; ok {
+		format.sep = sep
+	}
+
+	if kvSep, ok := tagOpts["kvsep"]; ok {
+		format.kvSep = kvSep
+	}
+
+	if format.sep == "" || format.kvSep == "" ||
+		strings.ContainsRune(format.sep, sliceQuote) || strings.ContainsRune(format.kvSep, sliceQuote) ||
+		strings.Contains(format.sep, format.kvSep) || strings.Contains(format.kvSep, format.sep) {
+		return format, fmt.Errorf("sep %q and kvsep %q: %w", format.sep, format.kvSep, ErrInvalidTag)
+	}
+
+	return format, nil
+}
+
+// split returns the pairs of a cell, unquoting their keys and values. An
+// empty cell has no pairs.
+func (f mapFormat) split(str string) ([][2]string, error) {
+	if str == "" {
+		return nil, nil
+	}
+
+	var pairs [][2]string
+
+	for {
+		key, rest, err := sliceFormat{sep: f.kvSep}.next(str)
+		if err != nil {
+			return nil, err
+		}
+
+		rest, found := strings.CutPrefix(rest, f.kvSep)
+		if !found {
+			return nil, fmt.Errorf("key %q without value: %w", key, strconv.ErrSyntax)
+		}
+
+		value, rest, err := sliceFormat{sep: f.sep}.next(rest)
+		if err != nil {
+			return nil, err
+		}
+
+		pairs = append(pairs, [2]string{key, value})
+
+		str, found = strings.CutPrefix(rest, f.sep)
+		if !found {
+			if rest != "" {
+				return nil, fmt.Errorf("text after quoted value %q: %w", value, strconv.ErrSyntax)
+			}
+
+			return pairs, nil
+		}
+	}
+}
+
+// join returns the cell holding the given pairs, quoting the keys and the
+// values which could not be told apart from the separator following them.
+func (f mapFormat) join(pairs [][2]string) string {
+	var sb strings.Builder
+
+	for i, pair := range pairs {
+		if i > 0 {
+			sb.WriteString(f.sep)
+		}
+
+		key, value := pair[0], pair[1]
+
+		if needsQuote(key, f.kvSep) {
+			key = quote(key)
+		}
+
+		if needsQuote(value, f.sep) {
+			value = quote(value)
+		}
+
+		sb.WriteString(key)
+		sb.WriteString(f.kvSep)
+		sb.WriteString(value)
+	}
+
+	return sb.String()
+}
+
+// mapDecoder returns the decode function of a map type, see [mapFormat].
+func (c *columnDescriptor) mapDecoder(t reflect.Type) decodeFunc {
+	decodeKey := c.decoder(t.Key())
+	decodeValue := c.decoder(t.Elem())
+	format := c.mapFormat
+
+	return func(str string, target reflect.Value) error {
+		pairs, err := format.split(str)
+		if err != nil {
+			return err
+		}
+
+		result := reflect.MakeMapWithSize(t, len(pairs))
+		key := reflect.New(t.Key()).Elem()
+		value := reflect.New(t.Elem()).Elem()
+
+		for _, pair := range pairs {
+			err := decodeKey(pair[0], key)
+			if err != nil {
+				return fmt.Errorf("parse map key %q: %w", pair[0], err)
+			}
+
+			// Decoders may only set part of the value.
+			value.SetZero()
+
+			err = decodeValue(pair[1], value)
+			if err != nil {
+				return fmt.Errorf("parse map key %q, string %q: %w", pair[0], pair[1], err)
+			}
+
+			result.SetMapIndex(key, value)
+		}
+
+		target.Set(result)
+
+		return nil
+	}
+}
+
+// mapEncoder returns the encode function of a map type, see [mapFormat]. The
+// pairs are sorted by key, so that the output is stable.
+func (c *columnDescriptor) mapEncoder(t reflect.Type) encodeFunc {
+	encodeKey := c.encoder(t.Key())
+	encodeValue := c.encoder(t.Elem())
+	format := c.mapFormat
+
+	return func(value reflect.Value) (string, error) {
+		pairs := make([][2]string, 0, value.Len())
+
+		iter := value.MapRange()
+		for iter.Next() {
+			key, err := encodeKey(iter.Key())
+			if err != nil {
+				return "", fmt.Errorf("map key: %w", err)
+			}
+
+			str, err := encodeValue(iter.Value())
+			if err != nil {
+				return "", fmt.Errorf("map key %q: %w", key, err)
+			}
+
+			pairs = append(pairs, [2]string{key, str})
+		}
+
+		slices.SortFunc(pairs, func(a, b [2]string) int {
+			return strings.Compare(a[0], b[0])
+		})
+
+		return format.join(pairs), nil
+	}
+}
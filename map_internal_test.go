@@ -0,0 +1,135 @@
+package goflat
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseMapFormat(t *testing.T) {
+	t.Run("error", testParseMapFormatError)
+	t.Run("success", testParseMapFormatSuccess)
+}
+
+func testParseMapFormatError(t *testing.T) {
+	tcs := map[string]struct {
+		tagOpts tagOptions
+		t       reflect.Type
+	}{
+		"not a map":      {tagOpts: tagOptions{"kvsep": ":"}, t: reflect.TypeFor[[]string]()},
+		"brackets":       {tagOpts: tagOptions{"brackets": "{}"}, t: reflect.TypeFor[map[string]int]()},
+		"empty kvsep":    {tagOpts: tagOptions{"kvsep": ""}, t: reflect.TypeFor[map[string]int]()},
+		"quote sep":      {tagOpts: tagOptions{"sep": `"`}, t: reflect.TypeFor[map[string]int]()},
+		"same separator": {tagOpts: tagOptions{"sep": "=="}, t: reflect.TypeFor[map[string]int]()},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseMapFormat(tc.tagOpts, tc.t)
+			if !errors.Is(err, ErrInvalidTag) {
+				t.Errorf("expected %v, got %v", ErrInvalidTag, err)
+			}
+		})
+	}
+}
+
+func testParseMapFormatSuccess(t *testing.T) {
+	tcs := map[string]struct {
+		tagOpts  tagOptions
+		t        reflect.Type
+		expected mapFormat
+	}{
+		"default": {
+			tagOpts:  tagOptions{},
+			t:        reflect.TypeFor[map[string]int](),
+			expected: mapFormat{sep: ";", kvSep: "="},
+		},
+		"pointer": {
+			tagOpts:  tagOptions{"sep": ", ", "kvsep": ": "},
+			t:        reflect.TypeFor[*map[string]string](),
+			expected: mapFormat{sep: ", ", kvSep: ": "},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseMapFormat(tc.tagOpts, tc.t)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expected, got, cmp.AllowUnexported(mapFormat{})); diff != "" {
+				t.Errorf("(-expected,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMapFormat(t *testing.T) {
+	t.Run("join", testMapFormatJoin)
+	t.Run("split", testMapFormatSplit)
+	t.Run("split error", testMapFormatSplitError)
+}
+
+func testMapFormatJoin(t *testing.T) {
+	format := mapFormat{sep: ";", kvSep: "="}
+
+	tcs := map[string]struct {
+		pairs    [][2]string
+		expected string
+	}{
+		"empty":     {pairs: nil, expected: ""},
+		"plain":     {pairs: [][2]string{{"a", "1"}, {"b", "2"}}, expected: "a=1;b=2"},
+		"empty key": {pairs: [][2]string{{"", ""}}, expected: "="},
+		"separator": {pairs: [][2]string{{"a=b", "c=d"}, {"e;f", "g;h"}}, expected: `"a=b"=c=d;e;f="g;h"`},
+		"quote":     {pairs: [][2]string{{`"k"`, `say "hi"`}}, expected: `"""k"""="say ""hi"""`},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got := format.join(tc.pairs)
+			if got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func testMapFormatSplit(t *testing.T) {
+	tcs := map[string]struct {
+		format   mapFormat
+		str      string
+		expected [][2]string
+	}{
+		"empty":      {format: mapFormat{sep: ";", kvSep: "="}, str: "", expected: nil},
+		"plain":      {format: mapFormat{sep: ";", kvSep: "="}, str: "a=1;b=2", expected: [][2]string{{"a", "1"}, {"b", "2"}}},
+		"empty pair": {format: mapFormat{sep: ";", kvSep: "="}, str: "=;a=", expected: [][2]string{{"", ""}, {"a", ""}}},
+		"quoted":     {format: mapFormat{sep: ";", kvSep: "="}, str: `"a=b"=c=d;"e;f"="g;h"`, expected: [][2]string{{"a=b", "c=d"}, {"e;f", "g;h"}}},
+		"long":       {format: mapFormat{sep: ", ", kvSep: ": "}, str: "a: 1, b: 2", expected: [][2]string{{"a", "1"}, {"b", "2"}}},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.format.split(tc.str)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expected, got); diff != "" {
+				t.Errorf("(-expected,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func testMapFormatSplitError(t *testing.T) {
+	for _, str := range []string{"a", `"a`, `"a"b=1`, `a="1"2`} {
+		_, err := mapFormat{sep: ";", kvSep: "="}.split(str)
+		if !errors.Is(err, strconv.ErrSyntax) {
+			t.Errorf("%q: expected %v, got %v", str, strconv.ErrSyntax, err)
+		}
+	}
+}
@@ -2,102 +2,176 @@ package goflat
 
 import (
 	"context"
-	"encoding/csv"
+	"errors"
 	"fmt"
 	"iter"
 )
 
+// RowWriter is implemented by the writers goflat can marshal to, such as
+// [*csv.Writer] and [*FixedWidthWriter]. Unless [Options.Headerless] is set,
+// the first row holds the headers.
+//
+// Once all the rows are written, the writer is flushed if it implements
+// either Flush() error or, like [*csv.Writer], Flush() and Error() error.
+type RowWriter interface {
+	Write(record []string) error
+}
+
 // Marshaller can be used to tell goflat to use custom logic to convert a field
-// into a string.
+// into a string. It takes precedence over [encoding.TextMarshaler] and
+// [fmt.Stringer].
 type Marshaller interface {
 	Marshal() (string, error)
 }
 
-// MarshalSliceToWriter marshals a slice of structs to a CSV file.
+// MarshalSliceToWriter marshals a slice of structs to a file.
 //
 // An options struct can be passed to modify the behaviour, use [StrictOptions]
 // if you're not sure about how to configure them.
 //
-// NOTE: this is a direct wrapper of [MarshalIteratorToWriter].
-func MarshalSliceToWriter[T any](ctx context.Context, values []T, writer *csv.Writer, opts Options) error {
-	return MarshalIteratorToWriter(ctx, sliceToIterator(values), writer, opts)
+// NOTE: this is a wrapper of [MarshalIteratorToWriter], but the whole slice
+// is used to compute the headers of the extra columns.
+func MarshalSliceToWriter[T any](ctx context.Context, values []T, writer RowWriter, opts Options) error {
+	return marshalIteratorToWriter(ctx, sliceToIterator(values), writer, opts, values)
 }
 
-// MarshalIteratorToWriter marshals an iterator of structs to a CSV file.
+// MarshalIteratorToWriter marshals an iterator of structs to a file.
 //
 // An options struct can be passed to modify the behaviour, use [StrictOptions]
 // if you're not sure about how to configure them.
-func MarshalIteratorToWriter[T any](ctx context.Context, seq iter.Seq[T], writer *csv.Writer, opts Options) error {
-	ch := make(chan T) //nolint:varnamelen // Fine here.
-
-	go func() {
-		defer close(ch)
-
-		for value := range seq {
-			select {
-			case <-ctx.Done():
-				return
-			case ch <- value:
-			}
-		}
-	}()
-
-	return MarshalChannelToWriter(ctx, ch, writer, opts)
+func MarshalIteratorToWriter[T any](ctx context.Context, seq iter.Seq[T], writer RowWriter, opts Options) error {
+	return marshalIteratorToWriter(ctx, seq, writer, opts, nil)
 }
 
-// MarshalChannelToWriter marshals a channel of structs to a CSV file.
+// MarshalChannelToWriter marshals a channel of structs to a file.
+//
+// If the struct has a field tagged with the `extra` option, the keys of its
+// map in the first value determine the additional columns: any subsequent
+// value introducing a new key causes [ErrUnknownColumn].
 //
 // An options struct can be passed to modify the behaviour, use [StrictOptions]
 // if you're not sure about how to configure them.
-func MarshalChannelToWriter[T any](ctx context.Context, inputCh <-chan T, writer *csv.Writer, opts Options) error {
-	opts.headersFromStruct = true
+func MarshalChannelToWriter[T any](ctx context.Context, inputCh <-chan T, writer RowWriter, opts Options) error {
+	return marshalIteratorToWriter(ctx, channelToIterator(ctx, inputCh), writer, opts, nil)
+}
 
-	factory, err := newFactory[T](nil, opts)
-	if err != nil {
-		return fmt.Errorf("new factory: %w", err)
+// marshalIteratorToWriter marshals the values of the iterator. The headers
+// are computed from the given samples or, if nil, from the first value.
+func marshalIteratorToWriter[T any](ctx context.Context, seq iter.Seq[T], writer RowWriter, opts Options, samples []T) error {
+	encoder := NewEncoder[T](writer, opts)
+	if encoder.err != nil {
+		return encoder.err
 	}
 
-	err = writer.Write(factory.marshalHeaders())
-	if err != nil {
-		return fmt.Errorf("write headers: %w", err)
+	if samples != nil {
+		err := encoder.writeHeader(samples...)
+		if err != nil {
+			return err
+		}
 	}
 
-	var (
-		currentLine int
-		value       T
-	)
-
-	for {
-		var channelHasValue bool
+	// first is called with the first value, before marshalling it.
+	first := func(value T) error {
+		return encoder.writeHeader(value)
+	}
 
-		select {
-		case <-ctx.Done():
-			return context.Cause(ctx) //nolint:wrapcheck // Fine here.
-		case value, channelHasValue = <-inputCh:
-		}
+	records := marshalRecords(ctx, seq, encoder.factory, first)
+	if opts.Workers > 1 {
+		records = marshalParallel(ctx, seq, encoder.factory, opts.Workers, first)
+	}
 
-		if !channelHasValue {
-			break
+	for record, err := range records {
+		if err != nil {
+			return err
 		}
 
-		record, err := factory.marshal(value)
+		err = encoder.writeRecord(record)
 		if err != nil {
-			return fmt.Errorf("marshal %d: %w", currentLine, err)
+			return err
 		}
+	}
 
-		err = writer.Write(record)
-		if err != nil {
-			return fmt.Errorf("write line %d: %w", currentLine, err)
+	if ctx.Err() != nil {
+		return context.Cause(ctx) //nolint:wrapcheck // Fine here.
+	}
+
+	err := encoder.WriteHeader()
+	if err != nil {
+		return err
+	}
+
+	return encoder.Flush()
+}
+
+// marshalRecords returns an iterator over the marshalled values of seq. It
+// ends with an error if a value cannot be marshalled or if the context is
+// done.
+func marshalRecords[T any](ctx context.Context, seq iter.Seq[T], factory *structFactory[T], first func(T) error) iter.Seq2[[]string, error] {
+	return func(yield func([]string, error) bool) {
+		var currentRow int
+
+		// current holds the value being marshalled, so that it is not copied
+		// to the heap for each row.
+		current := new(T)
+
+		for value := range seq {
+			if ctx.Err() != nil {
+				yield(nil, context.Cause(ctx))
+
+				return
+			}
+
+			if currentRow == 0 {
+				err := first(value)
+				if err != nil {
+					yield(nil, err)
+
+					return
+				}
+			}
+
+			*current = value
+
+			record, err := factory.marshal(current)
+			if err != nil {
+				yield(nil, marshalRowError(err, currentRow))
+
+				return
+			}
+
+			if !yield(record, nil) {
+				return
+			}
+
+			currentRow++
 		}
+	}
+}
 
-		currentLine++
+// marshalRowError adds the index of the row to the given marshalling error.
+func marshalRowError(err error, row int) error {
+	var marshalErr *MarshalError
+	if errors.As(err, &marshalErr) {
+		marshalErr.Row = row
+
+		return marshalErr
 	}
 
-	writer.Flush()
+	return fmt.Errorf("marshal %d: %w", row, err)
+}
 
-	err = writer.Error()
-	if err != nil {
-		return fmt.Errorf("flush: %w", err)
+// flush flushes the writer, if it buffers rows.
+func flush(writer RowWriter) error {
+	switch writer := writer.(type) {
+	case interface{ Flush() error }:
+		return writer.Flush() //nolint:wrapcheck // Wrapped by the caller.
+	case interface {
+		Flush()
+		Error() error
+	}:
+		writer.Flush()
+
+		return writer.Error() //nolint:wrapcheck // Wrapped by the caller.
 	}
 
 	return nil
@@ -112,3 +186,20 @@ func sliceToIterator[T any](slice []T) iter.Seq[T] {
 		}
 	}
 }
+
+// channelToIterator returns an iterator over the values of the channel,
+// which ends when the channel is closed or the context is done.
+func channelToIterator[T any](ctx context.Context, ch <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case value, ok := <-ch:
+				if !ok || !yield(value) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -2,8 +2,16 @@ package goflat_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
@@ -11,9 +19,73 @@ import (
 )
 
 func TestMarshal(t *testing.T) {
+	t.Run("error", testMarshalError)
 	t.Run("escaping", testMarshalEscaping)
 	t.Run("success", testMarshalSuccess)
 	t.Run("success pointer", testMarshalSuccessPointer)
+	t.Run("success nested", testMarshalSuccessNested)
+	t.Run("interfaces", testMarshalInterfaces)
+	t.Run("time", testMarshalTime)
+	t.Run("omitempty", testMarshalOmitEmpty)
+	t.Run("aliases", testMarshalAliases)
+	t.Run("extra", testMarshalExtra)
+	t.Run("headerless", testMarshalHeaderless)
+	t.Run("row writer", testMarshalRowWriter)
+	t.Run("parallel", testMarshalParallel)
+	t.Run("slice", testMarshalSlice)
+	t.Run("repeated", testMarshalRepeated)
+	t.Run("map", testMarshalMap)
+	t.Run("json", testMarshalJSON)
+	t.Run("number format", testMarshalNumberFormat)
+}
+
+var errMarshalFailing = errors.New("failing")
+
+type marshalFailing struct {
+	Fail bool
+}
+
+func (m marshalFailing) Marshal() (string, error) {
+	if m.Fail {
+		return "", errMarshalFailing
+	}
+
+	return "ok", nil
+}
+
+func testMarshalError(t *testing.T) {
+	type nested struct {
+		Value marshalFailing `flat:"value"`
+	}
+
+	type record struct {
+		Name   string `flat:"name"`
+		Nested nested `flat:"nested"`
+	}
+
+	input := []record{
+		{Name: "Guybrush"},
+		{Name: "Elaine", Nested: nested{Value: marshalFailing{Fail: true}}},
+	}
+
+	err := goflat.MarshalSliceToWriter(t.Context(), input, csv.NewWriter(&bytes.Buffer{}), goflat.Options{})
+
+	var marshalErr *goflat.MarshalError
+	if !errors.As(err, &marshalErr) {
+		t.Fatalf("expected %T, got %v", marshalErr, err)
+	}
+
+	if marshalErr.Row != 1 {
+		t.Errorf("expected row 1, got %d", marshalErr.Row)
+	}
+
+	if marshalErr.Field != "Nested.Value" {
+		t.Errorf("expected field %q, got %q", "Nested.Value", marshalErr.Field)
+	}
+
+	if !errors.Is(err, errMarshalFailing) {
+		t.Errorf("expected %v, got %v", errMarshalFailing, err)
+	}
 }
 
 func testMarshalEscaping(t *testing.T) {
@@ -193,3 +265,729 @@ func testMarshalSuccessPointer(t *testing.T) {
 		t.Errorf("(-expected, +got):\n%s", diff)
 	}
 }
+
+func testMarshalSuccessNested(t *testing.T) {
+	type address struct {
+		Street string `flat:"street"`
+		City   string `flat:"city"`
+	}
+
+	type Base struct {
+		ID int `flat:"id"`
+	}
+
+	type record struct {
+		Base
+		Name     string   `flat:"name"`
+		Address  address  `flat:"address"`
+		Previous *address `flat:"previous"`
+	}
+
+	input := []record{
+		{
+			Base:    Base{ID: 1},
+			Name:    "Guybrush",
+			Address: address{Street: "Main St", City: "Melee Island"},
+		},
+		{
+			Base:     Base{ID: 2},
+			Name:     "Elaine",
+			Address:  address{Street: "Mansion", City: "Melee Island"},
+			Previous: &address{Street: "Governor's House", City: "Booty Island"},
+		},
+	}
+
+	var got bytes.Buffer
+
+	writer := csv.NewWriter(&got)
+
+	err := goflat.MarshalSliceToWriter(t.Context(), input, writer, goflat.Options{})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	expected := `id,name,address.street,address.city,previous.street,previous.city
+1,Guybrush,Main St,Melee Island,nil,nil
+2,Elaine,Mansion,Melee Island,Governor's House,Booty Island
+`
+
+	if diff := cmp.Diff(expected, got.String()); diff != "" {
+		t.Errorf("(-expected, +got):\n%s", diff)
+	}
+}
+
+type marshalAll struct{}
+
+func (marshalAll) Marshal() (string, error)     { return "goflat", nil }
+func (marshalAll) MarshalText() ([]byte, error) { return []byte("text"), nil }
+func (marshalAll) String() string               { return "stringer" }
+
+type marshalText struct{}
+
+func (marshalText) MarshalText() ([]byte, error) { return []byte("text"), nil }
+func (marshalText) String() string               { return "stringer" }
+
+type marshalStringer int
+
+func (marshalStringer) String() string { return "stringer" }
+
+func testMarshalInterfaces(t *testing.T) {
+	type record struct {
+		All      marshalAll      `flat:"all"`
+		Text     marshalText     `flat:"text"`
+		Stringer marshalStringer `flat:"stringer"`
+		Addr     netip.Addr      `flat:"addr"`
+		Amount   *big.Int        `flat:"amount"`
+		Level    slog.Level      `flat:"level"`
+	}
+
+	input := []record{
+		{
+			Addr:   netip.MustParseAddr("192.168.0.1"),
+			Amount: big.NewInt(123456789),
+			Level:  slog.LevelWarn,
+		},
+	}
+
+	var got bytes.Buffer
+
+	writer := csv.NewWriter(&got)
+
+	err := goflat.MarshalSliceToWriter(t.Context(), input, writer, goflat.Options{})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	expected := `all,text,stringer,addr,amount,level
+goflat,text,stringer,192.168.0.1,123456789,WARN
+`
+
+	if diff := cmp.Diff(expected, got.String()); diff != "" {
+		t.Errorf("(-expected, +got):\n%s", diff)
+	}
+}
+
+func testMarshalTime(t *testing.T) {
+	type record struct {
+		Default  time.Time     `flat:"default"`
+		Date     time.Time     `flat:"date,layout=2006-01-02"`
+		Epoch    time.Time     `flat:"epoch,layout=unix"`
+		EpochMs  time.Time     `flat:"epoch_ms,layout=unixmilli"`
+		Optional *time.Time    `flat:"optional"`
+		Duration time.Duration `flat:"duration"`
+	}
+
+	moment := time.Date(2024, 3, 1, 10, 20, 30, 123000000, time.FixedZone("", 3600))
+
+	input := []record{
+		{
+			Default:  moment,
+			Date:     moment,
+			Epoch:    moment,
+			EpochMs:  moment,
+			Optional: &moment,
+			Duration: time.Hour + 30*time.Minute,
+		},
+	}
+
+	t.Run("default", func(t *testing.T) {
+		var got bytes.Buffer
+
+		writer := csv.NewWriter(&got)
+
+		err := goflat.MarshalSliceToWriter(t.Context(), input, writer, goflat.Options{})
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		expected := `default,date,epoch,epoch_ms,optional,duration
+2024-03-01T10:20:30.123+01:00,2024-03-01,1709284830,1709284830123,2024-03-01T10:20:30.123+01:00,1h30m0s
+`
+
+		if diff := cmp.Diff(expected, got.String()); diff != "" {
+			t.Errorf("(-expected, +got):\n%s", diff)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		var got bytes.Buffer
+
+		writer := csv.NewWriter(&got)
+
+		err := goflat.MarshalSliceToWriter(t.Context(), input, writer, goflat.Options{
+			TimeLayout:   time.DateTime,
+			TimeLocation: time.UTC,
+		})
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		expected := `default,date,epoch,epoch_ms,optional,duration
+2024-03-01 09:20:30,2024-03-01,1709284830,1709284830123,2024-03-01 09:20:30,1h30m0s
+`
+
+		if diff := cmp.Diff(expected, got.String()); diff != "" {
+			t.Errorf("(-expected, +got):\n%s", diff)
+		}
+	})
+}
+
+func testMarshalOmitEmpty(t *testing.T) {
+	type record struct {
+		Name    string     `flat:"name,omitempty"`
+		Age     int        `flat:"age,omitempty"`
+		Pirate  bool       `flat:"pirate,omitempty"`
+		Ship    *string    `flat:"ship,omitempty"`
+		Born    time.Time  `flat:"born,omitempty,layout=2006-01-02"`
+		Weight  float64    `flat:"weight"`
+		Married *time.Time `flat:"married"`
+	}
+
+	input := []record{
+		{Name: "Guybrush", Age: 28, Pirate: true, Ship: ptrTo("The Sea Cucumber"), Born: time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{},
+	}
+
+	var got bytes.Buffer
+
+	err := goflat.MarshalSliceToWriter(t.Context(), input, csv.NewWriter(&got), goflat.Options{})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	expected := `name,age,pirate,ship,born,weight,married
+Guybrush,28,true,The Sea Cucumber,1990-01-02,0,nil
+,,,,,0,nil
+`
+
+	if diff := cmp.Diff(expected, got.String()); diff != "" {
+		t.Errorf("(-expected, +got):\n%s", diff)
+	}
+}
+
+func testMarshalAliases(t *testing.T) {
+	type address struct {
+		City string `flat:"city|town"`
+	}
+
+	type record struct {
+		FirstName string  `flat:"first_name|firstname|given_name"`
+		Address   address `flat:"address|addr"`
+	}
+
+	var got bytes.Buffer
+
+	err := goflat.MarshalSliceToWriter(t.Context(), []record{{FirstName: "Guybrush", Address: address{City: "Melee"}}}, csv.NewWriter(&got), goflat.Options{})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	expected := `first_name,address.city
+Guybrush,Melee
+`
+
+	if diff := cmp.Diff(expected, got.String()); diff != "" {
+		t.Errorf("(-expected, +got):\n%s", diff)
+	}
+}
+
+func testMarshalExtra(t *testing.T) {
+	type record struct {
+		Name  string            `flat:"name"`
+		Extra map[string]string `flat:",extra"`
+	}
+
+	input := []record{
+		{Name: "Guybrush", Extra: map[string]string{"ship": "The Sea Cucumber", "crew": "3"}},
+		{Name: "LeChuck", Extra: map[string]string{"curse": "ghost"}},
+		{Name: "Elaine"},
+	}
+
+	t.Run("slice", func(t *testing.T) {
+		var got bytes.Buffer
+
+		err := goflat.MarshalSliceToWriter(t.Context(), input, csv.NewWriter(&got), goflat.Options{})
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		expected := `name,crew,curse,ship
+Guybrush,3,,The Sea Cucumber
+LeChuck,,ghost,
+Elaine,,,
+`
+
+		if diff := cmp.Diff(expected, got.String()); diff != "" {
+			t.Errorf("(-expected, +got):\n%s", diff)
+		}
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		err := goflat.MarshalIteratorToWriter(t.Context(), slices.Values(input), csv.NewWriter(&bytes.Buffer{}), goflat.Options{})
+		if !errors.Is(err, goflat.ErrUnknownColumn) {
+			t.Errorf("expected %v, got %v", goflat.ErrUnknownColumn, err)
+		}
+	})
+
+	t.Run("duplicated header", func(t *testing.T) {
+		input := []record{{Name: "Guybrush", Extra: map[string]string{"name": "Threepwood"}}}
+
+		err := goflat.MarshalSliceToWriter(t.Context(), input, csv.NewWriter(&bytes.Buffer{}), goflat.Options{})
+		if !errors.Is(err, goflat.ErrDuplicatedHeader) {
+			t.Errorf("expected %v, got %v", goflat.ErrDuplicatedHeader, err)
+		}
+	})
+}
+
+func testMarshalRepeated(t *testing.T) {
+	type item struct {
+		SKU string `flat:"sku"`
+		Qty int    `flat:"qty"`
+	}
+
+	type record struct {
+		Name   string   `flat:"name"`
+		Phones []string `flat:"phone_{n}"`
+		Items  []*item  `flat:"item_{n}"`
+	}
+
+	input := []record{
+		{Name: "Guybrush", Phones: []string{"555-0101"}, Items: []*item{{SKU: "ROOT-BEER", Qty: 2}, nil}},
+		{Name: "LeChuck", Phones: []string{"555-0201", "555-0202"}},
+	}
+
+	t.Run("slice", func(t *testing.T) {
+		var got bytes.Buffer
+
+		err := goflat.MarshalSliceToWriter(t.Context(), input, csv.NewWriter(&got), goflat.Options{})
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		expected := `name,phone_1,phone_2,item_1.sku,item_1.qty,item_2.sku,item_2.qty
+Guybrush,555-0101,,ROOT-BEER,2,nil,nil
+LeChuck,555-0201,555-0202,,,,
+`
+
+		if diff := cmp.Diff(expected, got.String()); diff != "" {
+			t.Errorf("(-expected, +got):\n%s", diff)
+		}
+	})
+
+	t.Run("max", func(t *testing.T) {
+		type record struct {
+			Phones []string `flat:"phone_{n},max=3"`
+		}
+
+		var got bytes.Buffer
+
+		err := goflat.MarshalIteratorToWriter(t.Context(), slices.Values([]record{{Phones: []string{"555-0101"}}}), csv.NewWriter(&got), goflat.Options{})
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		expected := `phone_1,phone_2,phone_3
+555-0101,,
+`
+
+		if diff := cmp.Diff(expected, got.String()); diff != "" {
+			t.Errorf("(-expected, +got):\n%s", diff)
+		}
+	})
+
+	t.Run("trailing empty items", func(t *testing.T) {
+		type record struct {
+			Phones []string `flat:"phone_{n}"`
+		}
+
+		var buffer bytes.Buffer
+
+		err := goflat.MarshalSliceToWriter(t.Context(), []record{
+			{Phones: []string{"", "555-0102", "", ""}},
+			{Phones: []string{"", ""}},
+		}, csv.NewWriter(&buffer), goflat.Options{})
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(&buffer), goflat.Options{})
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		// Empty items cannot be told apart from missing ones, so only those
+		// followed by an item with a value are read back.
+		expected := []record{{Phones: []string{"", "555-0102"}}, {}}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected, +got):\n%s", diff)
+		}
+	})
+
+	t.Run("too many items", func(t *testing.T) {
+		err := goflat.MarshalIteratorToWriter(t.Context(), slices.Values(input), csv.NewWriter(&bytes.Buffer{}), goflat.Options{})
+		if !errors.Is(err, goflat.ErrTooManyItems) {
+			t.Fatalf("expected %v, got %v", goflat.ErrTooManyItems, err)
+		}
+
+		var marshalErr *goflat.MarshalError
+		if !errors.As(err, &marshalErr) || marshalErr.Row != 1 || marshalErr.Field != "Phones" {
+			t.Errorf("expected an error on row 1, field Phones, got %v", err)
+		}
+	})
+}
+
+func testMarshalMap(t *testing.T) {
+	type record struct {
+		Name   string            `flat:"name"`
+		Counts map[string]int    `flat:"counts"`
+		Attrs  map[string]string `flat:"attr,expand"`
+	}
+
+	input := []record{
+		{Name: "Guybrush", Counts: map[string]int{"rum": 2, "gold": 8}, Attrs: map[string]string{"size": "M"}},
+		{Name: "LeChuck", Counts: map[string]int{}, Attrs: map[string]string{"color": "red", "size": "XL"}},
+		{Name: "Elaine"},
+	}
+
+	t.Run("slice", func(t *testing.T) {
+		var got bytes.Buffer
+
+		err := goflat.MarshalSliceToWriter(t.Context(), input, csv.NewWriter(&got), goflat.Options{})
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		expected := `name,counts,attr.color,attr.size
+Guybrush,gold=8;rum=2,,M
+LeChuck,,red,XL
+Elaine,nil,,
+`
+
+		if diff := cmp.Diff(expected, got.String()); diff != "" {
+			t.Errorf("(-expected, +got):\n%s", diff)
+		}
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		err := goflat.MarshalIteratorToWriter(t.Context(), slices.Values(input), csv.NewWriter(&bytes.Buffer{}), goflat.Options{})
+		if !errors.Is(err, goflat.ErrUnknownColumn) {
+			t.Errorf("expected %v, got %v", goflat.ErrUnknownColumn, err)
+		}
+	})
+}
+
+func testMarshalJSON(t *testing.T) {
+	type payload struct {
+		Ship string `json:"ship"`
+		Crew int    `json:"crew"`
+	}
+
+	type record struct {
+		Name    string            `flat:"name"`
+		Payload *payload          `flat:"payload,json"`
+		Tags    map[string]string `flat:"tags,json"`
+	}
+
+	input := []record{
+		{Name: "Guybrush", Payload: &payload{Ship: "The Sea Cucumber", Crew: 3}, Tags: map[string]string{"role": "<pirate>"}},
+		{Name: "LeChuck"},
+	}
+
+	var got bytes.Buffer
+
+	err := goflat.MarshalSliceToWriter(t.Context(), input, csv.NewWriter(&got), goflat.Options{})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	expected := `name,payload,tags
+Guybrush,"{""ship"":""The Sea Cucumber"",""crew"":3}","{""role"":""<pirate>""}"
+LeChuck,nil,nil
+`
+
+	if diff := cmp.Diff(expected, got.String()); diff != "" {
+		t.Errorf("(-expected, +got):\n%s", diff)
+	}
+}
+
+func testMarshalNumberFormat(t *testing.T) {
+	type record struct {
+		Amount   float64 `flat:"amount,precision=2"`
+		Quantity uint    `flat:"quantity"`
+		Discount float64 `flat:"discount,percent"`
+		Balance  int     `flat:"balance,currency=€,accounting"`
+		Rounded  float64 `flat:"rounded,precision=0"`
+		Share    float64 `flat:"share,percent,precision=0"`
+	}
+
+	input := []record{
+		{Amount: 1234.5, Quantity: 1000, Discount: 0.125, Balance: -1200, Rounded: 2.6, Share: 0.126},
+		{Amount: -0.004, Quantity: 7, Discount: 0.03, Balance: 300, Rounded: -0.4, Share: -0.004},
+	}
+
+	var got bytes.Buffer
+
+	writer := csv.NewWriter(&got)
+	writer.Comma = ';'
+
+	err := goflat.MarshalSliceToWriter(t.Context(), input, writer, goflat.Options{
+		NumberFormat: goflat.NumberFormat{Decimal: ',', Thousands: '.'},
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	expected := `amount;quantity;discount;balance;rounded;share
+1.234,50;1.000;12,5%;(€1.200);3;13%
+0,00;7;3%;€300;0;0%
+`
+
+	if diff := cmp.Diff(expected, got.String()); diff != "" {
+		t.Errorf("(-expected, +got):\n%s", diff)
+	}
+}
+
+func testMarshalHeaderless(t *testing.T) {
+	type record struct {
+		FirstName string `flat:"first_name"`
+		Age       int    `flat:"age,index=3"`
+		LastName  string `flat:"last_name"`
+		Ship      string `flat:"#5"`
+	}
+
+	input := []record{
+		{FirstName: "Guybrush", LastName: "Threepwood", Age: 28, Ship: "The Sea Cucumber"},
+		{FirstName: "Elaine", LastName: "Marley", Age: 20},
+	}
+
+	tcs := map[string]struct {
+		options  goflat.Options
+		expected string
+	}{
+		"headers": {
+			options: goflat.Options{},
+			expected: `first_name,last_name,,age,,
+Guybrush,Threepwood,,28,,The Sea Cucumber
+Elaine,Marley,,20,,
+`,
+		},
+		"headerless": {
+			options: goflat.Options{Headerless: true},
+			expected: `Guybrush,Threepwood,,28,,The Sea Cucumber
+Elaine,Marley,,20,,
+`,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var got bytes.Buffer
+
+			err := goflat.MarshalSliceToWriter(t.Context(), input, csv.NewWriter(&got), tc.options)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expected, got.String()); diff != "" {
+				t.Errorf("(-expected, +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		options := goflat.Options{Headerless: true, ErrorIfMissingHeaders: true}
+
+		var buffer bytes.Buffer
+
+		err := goflat.MarshalSliceToWriter(t.Context(), input, csv.NewWriter(&buffer), options)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(&buffer), options)
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if diff := cmp.Diff(input, got); diff != "" {
+			t.Errorf("(-expected, +got):\n%s", diff)
+		}
+	})
+}
+
+func testMarshalRowWriter(t *testing.T) {
+	type record struct {
+		Name string `flat:"name"`
+		Age  int    `flat:"age"`
+	}
+
+	input := []record{
+		{Name: "Guybrush", Age: 28},
+		{Name: "Elaine", Age: 20},
+	}
+
+	expected := [][]string{
+		{"name", "age"},
+		{"Guybrush", "28"},
+		{"Elaine", "20"},
+	}
+
+	writer := &tableWriter{}
+
+	err := goflat.MarshalSliceToWriter(t.Context(), input, writer, goflat.Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if diff := cmp.Diff(expected, writer.rows); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+var errWriteFailing = errors.New("write failing")
+
+// failingWriter fails to write the row with the given index, headers
+// included.
+type failingWriter struct {
+	failAt int
+	rows   int
+}
+
+func (w *failingWriter) Write([]string) error {
+	if w.rows == w.failAt {
+		return errWriteFailing
+	}
+
+	w.rows++
+
+	return nil
+}
+
+func testMarshalParallel(t *testing.T) {
+	type record struct {
+		ID    int            `flat:"id"`
+		Value marshalFailing `flat:"value"`
+	}
+
+	const rows = 1000
+
+	input := make([]record, rows)
+	for i := range input {
+		input[i] = record{ID: i}
+	}
+
+	expected := [][]string{{"id", "value"}}
+	for i := range rows {
+		expected = append(expected, []string{strconv.Itoa(i), "ok"})
+	}
+
+	opts := goflat.Options{Workers: 8}
+
+	t.Run("order", func(t *testing.T) {
+		writer := &tableWriter{}
+
+		err := goflat.MarshalIteratorToWriter(t.Context(), slices.Values(input), writer, opts)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if diff := cmp.Diff(expected, writer.rows); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("channel", func(t *testing.T) {
+		ch := make(chan record)
+
+		go func() {
+			defer close(ch)
+
+			for _, value := range input {
+				ch <- value
+			}
+		}()
+
+		writer := &tableWriter{}
+
+		err := goflat.MarshalChannelToWriter(t.Context(), ch, writer, opts)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if diff := cmp.Diff(expected, writer.rows); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("marshal error", func(t *testing.T) {
+		input := slices.Clone(input)
+		input[500].Value.Fail = true
+		input[900].Value.Fail = true
+
+		err := goflat.MarshalSliceToWriter(t.Context(), input, &tableWriter{}, opts)
+
+		var marshalErr *goflat.MarshalError
+		if !errors.As(err, &marshalErr) {
+			t.Fatalf("expected %T, got %v", marshalErr, err)
+		}
+
+		if marshalErr.Row != 500 {
+			t.Errorf("expected row 500, got %d", marshalErr.Row)
+		}
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		// The channel is never closed: marshalling must stop anyway.
+		ch := make(chan record, rows)
+		for _, value := range input {
+			ch <- value
+		}
+
+		err := goflat.MarshalChannelToWriter(t.Context(), ch, &failingWriter{failAt: 100}, opts)
+		if !errors.Is(err, errWriteFailing) {
+			t.Errorf("expected %v, got %v", errWriteFailing, err)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		err := goflat.MarshalSliceToWriter(ctx, input, &tableWriter{}, opts)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected %v, got %v", context.Canceled, err)
+		}
+	})
+}
+
+func testMarshalSlice(t *testing.T) {
+	type record struct {
+		Tags   []string `flat:"tags"`
+		Scores []int    `flat:"scores,sep=;,brackets=[]"`
+	}
+
+	input := []record{
+		{Tags: []string{"pirate", "captain, mighty"}, Scores: []int{1, 2}},
+		{Tags: []string{}, Scores: []int{}},
+		{},
+	}
+
+	var got bytes.Buffer
+
+	err := goflat.MarshalSliceToWriter(t.Context(), input, csv.NewWriter(&got), goflat.Options{})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	// Empty slices are written as such, while nil ones use the nil value.
+	expected := `tags,scores
+"pirate,""captain, mighty""",[1;2]
+,[]
+nil,nil
+`
+
+	if diff := cmp.Diff(expected, got.String()); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+}
@@ -0,0 +1,127 @@
+package goflat
+
+import (
+	"reflect"
+	"slices"
+)
+
+// nilGroup holds the columns of a nested struct pointer. Since a nil pointer
+// is marshalled as [Options.NilValue] in each of its columns, the pointer is
+// only unmarshalled as nil if all of them hold it: otherwise the value of a
+// field which happens to be equal to the nil value would be lost.
+type nilGroup struct {
+	// index is the path to the pointer starting from the root struct. It is
+	// nil for the items of repeated fields, which are only allocated when one
+	// of their columns is set.
+	index []int
+	// columns are the indices of the columns in the record.
+	columns []int
+}
+
+// bindNilGroups groups the bound columns by the nested struct pointers
+// containing them, including those of the items of repeated fields.
+func (s *structFactory[T]) bindNilGroups() {
+	for _, path := range s.pointerStructs {
+		var columns []int
+
+		for j, mappedIndex := range s.columnMap {
+			if isWithin(s.columns[mappedIndex].index, path) {
+				columns = append(columns, j)
+			}
+		}
+
+		s.addNilGroup(path, columns)
+	}
+
+	type itemStruct struct {
+		field, item, path int
+	}
+
+	itemGroups := map[itemStruct][]int{}
+
+	for j, binding := range s.repeatedMap {
+		field := s.repeated[binding.field]
+
+		for k, path := range field.pointerStructs {
+			if isWithin(field.columns[binding.column].index, path) {
+				key := itemStruct{field: binding.field, item: binding.item, path: k}
+				itemGroups[key] = append(itemGroups[key], j)
+			}
+		}
+	}
+
+	for _, columns := range itemGroups {
+		s.addNilGroup(nil, columns)
+	}
+}
+
+func (s *structFactory[T]) addNilGroup(index []int, columns []int) {
+	if len(columns) == 0 {
+		return
+	}
+
+	slices.Sort(columns)
+
+	if s.columnNilGroups == nil {
+		s.columnNilGroups = make(map[int][]int)
+	}
+
+	for _, j := range columns {
+		s.columnNilGroups[j] = append(s.columnNilGroups[j], len(s.nilGroups))
+	}
+
+	s.nilGroups = append(s.nilGroups, nilGroup{index: index, columns: columns})
+}
+
+// allNil returns whether all the columns of the group hold the nil value.
+func (s *structFactory[T]) allNil(record []string, group nilGroup) bool {
+	for _, j := range group.columns {
+		column := ""
+		if j < len(record) {
+			column = record[j]
+		}
+
+		if !s.isNil(column) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inNilStruct returns whether the column at the given index belongs to a
+// nested struct pointer which is nil in the record, see [nilGroup].
+func (s *structFactory[T]) inNilStruct(record []string, i int) bool {
+	if len(s.nilGroups) == 0 || !s.isNil(record[i]) {
+		return false
+	}
+
+	for _, group := range s.columnNilGroups[i] {
+		if s.allNil(record, s.nilGroups[group]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clearNilStructs sets the nested struct pointers which are nil in the record
+// to nil, since they may hold the value of a previous row.
+func (s *structFactory[T]) clearNilStructs(newStruct reflect.Value, record []string) {
+	for _, group := range s.nilGroups {
+		if group.index == nil || !s.allNil(record, group) {
+			continue
+		}
+
+		field := fieldByIndex(newStruct, group.index)
+		if field.IsValid() {
+			field.SetZero()
+		}
+	}
+}
+
+// isWithin returns whether the field at the given index is within the struct
+// at the given path.
+func isWithin(index, path []int) bool {
+	return len(index) >= len(path) && slices.Equal(index[:len(path)], path)
+}
@@ -0,0 +1,417 @@
+package goflat
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NumberFormat describes how integers and floats are written in cells, for
+// files following locale or accounting conventions such as "1.234,56",
+// "$3.00", "12%" or "(42)". The zero value is the plain format of [strconv].
+//
+// It can be set for every field with [Options.NumberFormat] and overridden
+// for single fields with the tag options of the same name: decimal,
+// thousands, percent, currency, accounting and precision.
+type NumberFormat struct {
+	// Decimal is the decimal separator. Defaults to '.'.
+	Decimal rune
+	// Thousands is the separator written between groups of three digits of
+	// the integer part, and ignored anywhere in it when parsing. Numbers are
+	// not grouped by default.
+	Thousands rune
+	// Percent causes floats to be written as percentages, 0.12 being written
+	// as "12%". When parsing, the percent sign is optional but values are
+	// still percentages. Integers are neither scaled nor written with the
+	// sign.
+	Percent bool
+	// Currency is a symbol written before the number, after any minus sign,
+	// e.g. "-$3". When parsing, it is accepted either before or after the
+	// number, optionally separated by spaces.
+	Currency string
+	// Accounting causes negative numbers to be written in parentheses, e.g.
+	// "(42)". A leading minus is still accepted when parsing.
+	Accounting bool
+	// Precision is the number of decimals of floats when marshalling, only
+	// used if FixedPrecision is set: otherwise floats are written with the
+	// shortest representation which reads back the same value. The precision
+	// tag option sets both.
+	Precision      int
+	FixedPrecision bool
+}
+
+// numberOptions are the tag options overriding [NumberFormat].
+//
+//nolint:gochecknoglobals // Used for validation.
+var numberOptions = []string{"decimal", "thousands", "percent", "currency", "accounting", "precision"}
+
+// parseNumberFormat returns the number format of a field, overriding the
+// given one with the tag options.
+//
+//nolint:cyclop // Fine-ish here.
+func parseNumberFormat(format NumberFormat, tagOpts tagOptions, t reflect.Type) (NumberFormat, error) {
+	hasOptions := false
+
+	for _, option := range numberOptions {
+		hasOptions = hasOptions || tagOpts.has(option)
+	}
+
+	if hasOptions && !isNumeric(t) {
+		return format, fmt.Errorf("number options on type %s: %w", t, ErrInvalidTag)
+	}
+
+	var err error
+
+	if decimal, ok := tagOpts["decimal"]; ok {
+		format.Decimal, err = parseSeparator(decimal)
+		if err != nil || format.Decimal == 0 {
+			return format, fmt.Errorf("decimal %q: %w", decimal, ErrInvalidTag)
+		}
+	}
+
+	if thousands, ok := tagOpts["thousands"]; ok {
+		format.Thousands, err = parseSeparator(thousands)
+		if err != nil {
+			return format, fmt.Errorf("thousands %q: %w", thousands, ErrInvalidTag)
+		}
+	}
+
+	if currency, ok := tagOpts["currency"]; ok {
+		format.Currency = currency
+	}
+
+	if precision, ok := tagOpts["precision"]; ok {
+		format.Precision, err = strconv.Atoi(precision)
+		if err != nil {
+			return format, fmt.Errorf("precision %q: %w", precision, ErrInvalidTag)
+		}
+
+		format.FixedPrecision = true
+	}
+
+	format.Percent = format.Percent || tagOpts.has("percent")
+	format.Accounting = format.Accounting || tagOpts.has("accounting")
+
+	err = format.validate()
+	if err != nil {
+		return format, fmt.Errorf("%w: %w", ErrInvalidTag, err)
+	}
+
+	return format, nil
+}
+
+// parseSeparator returns the single rune of a separator option, or 0 if
+// empty.
+func parseSeparator(str string) (rune, error) {
+	if str == "" {
+		return 0, nil
+	}
+
+	r, size := utf8.DecodeRuneInString(str)
+	if size != len(str) {
+		return 0, fmt.Errorf("separator %q is not a single character: %w", str, ErrInvalidTag)
+	}
+
+	return r, nil
+}
+
+// validate returns an error if numbers could not be read back once written
+// with the format.
+func (f NumberFormat) validate() error {
+	// Signs and digits are reserved, as well as exponents for separators.
+	reserved := func(r rune) bool {
+		return unicode.IsDigit(r) || strings.ContainsRune("-+()%", r)
+	}
+
+	switch {
+	case f.Decimal != 0 && (reserved(f.Decimal) || f.Decimal == 'e' || f.Decimal == 'E'):
+		return fmt.Errorf("decimal separator %q", f.Decimal)
+	case f.Thousands != 0 && (reserved(f.Thousands) || f.Thousands == 'e' || f.Thousands == 'E' || f.Thousands == f.decimal()):
+		return fmt.Errorf("thousands separator %q", f.Thousands)
+	case strings.ContainsFunc(f.Currency, reserved):
+		return fmt.Errorf("currency %q", f.Currency)
+	case f.Precision < 0:
+		return fmt.Errorf("precision %d", f.Precision)
+	}
+
+	return nil
+}
+
+// decimal returns the decimal separator.
+func (f NumberFormat) decimal() rune {
+	if f.Decimal == 0 {
+		return '.'
+	}
+
+	return f.Decimal
+}
+
+// isNumeric returns whether the given type holds integers or floats, either
+// directly or as the items of a pointer, a slice or a map.
+func isNumeric(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+
+	return isNumberKind(t.Kind())
+}
+
+// isNumberKind returns whether values of the given kind are converted
+// according to [NumberFormat].
+func isNumberKind(kind reflect.Kind) bool {
+	//nolint:exhaustive // Fine here, there's a default.
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// normalize converts a number written with the format to the syntax of
+// [strconv]. Percentages are divided by 100 if scale is true.
+//
+//nolint:cyclop // Fine-ish here.
+func (f NumberFormat) normalize(str string, scale bool) (string, error) {
+	str = strings.TrimSpace(str)
+
+	negative := false
+
+	if f.Accounting && strings.HasPrefix(str, "(") && strings.HasSuffix(str, ")") {
+		negative = true
+		str = strings.TrimSpace(str[1 : len(str)-1])
+	}
+
+	// The sign may come either before or after the currency.
+	for range 2 {
+		if rest, ok := strings.CutPrefix(str, "-"); ok && !negative {
+			negative = true
+			str = rest
+		}
+
+		if f.Currency == "" {
+			break
+		}
+
+		if rest, ok := strings.CutPrefix(str, f.Currency); ok {
+			str = strings.TrimSpace(rest)
+		} else if rest, ok := strings.CutSuffix(str, f.Currency); ok {
+			str = strings.TrimSpace(rest)
+		}
+	}
+
+	if f.Percent {
+		str = strings.TrimSpace(strings.TrimSuffix(str, "%"))
+	}
+
+	if f.Thousands != 0 {
+		str = strings.ReplaceAll(str, string(f.Thousands), "")
+	}
+
+	if f.Decimal != 0 && f.Decimal != '.' {
+		if strings.ContainsRune(str, '.') {
+			return "", fmt.Errorf("unexpected '.' in %q: %w", str, strconv.ErrSyntax)
+		}
+
+		str = strings.ReplaceAll(str, string(f.Decimal), ".")
+	}
+
+	if f.Percent && scale {
+		str = shiftDecimal(str, -2)
+	}
+
+	if negative {
+		str = "-" + str
+	}
+
+	return str, nil
+}
+
+// format writes a number in the syntax of [strconv] with the format. Floats
+// must already be scaled if they are percentages.
+func (f NumberFormat) format(str string, percent bool) string {
+	str, negative := strings.CutPrefix(str, "-")
+
+	intPart, fracPart, hasFrac := strings.Cut(str, ".")
+
+	var sb strings.Builder
+
+	switch {
+	case negative && f.Accounting:
+		sb.WriteByte('(')
+	case negative:
+		sb.WriteByte('-')
+	}
+
+	sb.WriteString(f.Currency)
+
+	for i, digit := range intPart {
+		if i > 0 && f.Thousands != 0 && (len(intPart)-i)%3 == 0 {
+			sb.WriteRune(f.Thousands)
+		}
+
+		sb.WriteRune(digit)
+	}
+
+	if hasFrac {
+		sb.WriteRune(f.decimal())
+		sb.WriteString(fracPart)
+	}
+
+	if percent {
+		sb.WriteByte('%')
+	}
+
+	if negative && f.Accounting {
+		sb.WriteByte(')')
+	}
+
+	return sb.String()
+}
+
+// shiftDecimal moves the decimal point of an unsigned number in the syntax of
+// [strconv] by the given number of digits, to the right if positive. Numbers
+// with an exponent are returned as they are.
+func shiftDecimal(str string, shift int) string {
+	if strings.ContainsAny(str, "eE") || str == "" {
+		return str
+	}
+
+	intPart, fracPart, _ := strings.Cut(str, ".")
+	digits := intPart + fracPart
+	point := len(intPart) + shift
+
+	switch {
+	case point <= 0:
+		digits = strings.Repeat("0", 1-point) + digits
+		point = 1
+	case point > len(digits):
+		digits += strings.Repeat("0", point-len(digits))
+	}
+
+	intPart, fracPart = digits[:point], digits[point:]
+
+	intPart = strings.TrimLeft(intPart, "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	if fracPart == "" {
+		return intPart
+	}
+
+	return intPart + "." + fracPart
+}
+
+// numberDecoder returns the decode function of an integer or float type,
+// following the number format of the column.
+func (c *columnDescriptor) numberDecoder(t reflect.Type) decodeFunc {
+	format := c.number
+	isFloat := t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+
+	return func(str string, target reflect.Value) error {
+		str, err := format.normalize(str, isFloat)
+		if err != nil {
+			return err
+		}
+
+		//nolint:exhaustive // Only number kinds get here.
+		switch t.Kind() {
+		case reflect.Float32, reflect.Float64:
+			value, err := strconv.ParseFloat(str, t.Bits())
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			target.SetFloat(value)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			value, err := strconv.ParseUint(str, 10, t.Bits())
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			target.SetUint(value)
+		default:
+			value, err := strconv.ParseInt(str, 10, t.Bits())
+			if err != nil {
+				return err //nolint:wrapcheck // Wrapped by the caller.
+			}
+
+			target.SetInt(value)
+		}
+
+		return nil
+	}
+}
+
+// numberEncoder returns the encode function of an integer or float type,
+// following the number format of the column.
+func (c *columnDescriptor) numberEncoder(t reflect.Type) encodeFunc {
+	format := c.number
+
+	//nolint:exhaustive // Only number kinds get here.
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return func(value reflect.Value) (string, error) {
+			f := value.Float()
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return strconv.FormatFloat(f, 'f', -1, t.Bits()), nil
+			}
+
+			str := formatFloat(f, t.Bits(), format.precision(), format.Percent)
+			if strings.Trim(str, "-0.") == "" {
+				// Values rounded to zero have no sign either.
+				str = strings.TrimPrefix(str, "-")
+			}
+
+			return format.format(str, format.Percent), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(value reflect.Value) (string, error) {
+			return format.format(strconv.FormatUint(value.Uint(), 10), false), nil
+		}
+	default:
+		return func(value reflect.Value) (string, error) {
+			return format.format(strconv.FormatInt(value.Int(), 10), false), nil
+		}
+	}
+}
+
+// formatFloat returns the float in the syntax of [strconv] without exponent,
+// with the given number of decimals or the shortest representation if -1.
+// Percentages are multiplied by 100 by moving the decimal point, so that no
+// rounding error is introduced.
+func formatFloat(f float64, bits, precision int, percent bool) string {
+	if !percent {
+		return strconv.FormatFloat(f, 'f', precision, bits)
+	}
+
+	if precision >= 0 {
+		precision += 2
+	}
+
+	str, negative := strings.CutPrefix(strconv.FormatFloat(f, 'f', precision, bits), "-")
+
+	str = shiftDecimal(str, 2)
+	if negative {
+		str = "-" + str
+	}
+
+	return str
+}
+
+// precision returns the number of decimals of floats as expected by
+// [strconv.FormatFloat], -1 meaning the shortest representation.
+func (f NumberFormat) precision() int {
+	if !f.FixedPrecision {
+		return -1
+	}
+
+	return f.Precision
+}
@@ -0,0 +1,131 @@
+package goflat
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseNumberFormat(t *testing.T) {
+	tcs := map[string]struct {
+		tagOpts tagOptions
+		t       reflect.Type
+	}{
+		"not a number":       {tagOpts: tagOptions{"percent": ""}, t: reflect.TypeFor[string]()},
+		"long decimal":       {tagOpts: tagOptions{"decimal": ",,"}, t: reflect.TypeFor[float64]()},
+		"empty decimal":      {tagOpts: tagOptions{"decimal": ""}, t: reflect.TypeFor[float64]()},
+		"digit decimal":      {tagOpts: tagOptions{"decimal": "0"}, t: reflect.TypeFor[float64]()},
+		"same separators":    {tagOpts: tagOptions{"thousands": "."}, t: reflect.TypeFor[int]()},
+		"sign currency":      {tagOpts: tagOptions{"currency": "-"}, t: reflect.TypeFor[int]()},
+		"negative precision": {tagOpts: tagOptions{"precision": "-1"}, t: reflect.TypeFor[float64]()},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseNumberFormat(NumberFormat{}, tc.tagOpts, tc.t)
+			if !errors.Is(err, ErrInvalidTag) {
+				t.Errorf("expected %v, got %v", ErrInvalidTag, err)
+			}
+		})
+	}
+}
+
+func TestNumberFormat(t *testing.T) {
+	t.Run("normalize", testNumberFormatNormalize)
+	t.Run("format", testNumberFormatFormat)
+	t.Run("shift decimal", testNumberFormatShiftDecimal)
+}
+
+func testNumberFormatNormalize(t *testing.T) {
+	european := NumberFormat{Decimal: ',', Thousands: '.'}
+	accounting := NumberFormat{Thousands: ',', Currency: "$", Accounting: true}
+
+	tcs := map[string]struct {
+		format   NumberFormat
+		str      string
+		scale    bool
+		expected string
+	}{
+		"european":          {format: european, str: "1.234,56", expected: "1234.56"},
+		"english":           {format: NumberFormat{Thousands: ','}, str: "1,234.56", expected: "1234.56"},
+		"percent":           {format: NumberFormat{Percent: true}, str: "12%", scale: true, expected: "0.12"},
+		"percent unscaled":  {format: NumberFormat{Percent: true}, str: "12 %", expected: "12"},
+		"percent no sign":   {format: NumberFormat{Percent: true}, str: "-0.5", scale: true, expected: "-0.005"},
+		"currency prefix":   {format: accounting, str: "$3.00", expected: "3.00"},
+		"currency suffix":   {format: NumberFormat{Currency: "€", Decimal: ','}, str: "3,50 €", expected: "3.50"},
+		"currency sign":     {format: accounting, str: "-$1,000", expected: "-1000"},
+		"sign currency":     {format: accounting, str: "$-1,000", expected: "-1000"},
+		"accounting":        {format: accounting, str: "(42)", expected: "-42"},
+		"accounting symbol": {format: accounting, str: "($1,234.5)", expected: "-1234.5"},
+		"double negative":   {format: accounting, str: "(-42)", expected: "--42"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.format.normalize(tc.str, tc.scale)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+
+	t.Run("point with comma decimal", func(t *testing.T) {
+		_, err := NumberFormat{Decimal: ','}.normalize("1.5", false)
+		if err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+}
+
+func testNumberFormatFormat(t *testing.T) {
+	tcs := map[string]struct {
+		format   NumberFormat
+		str      string
+		percent  bool
+		expected string
+	}{
+		"plain":      {format: NumberFormat{}, str: "-1234.5", expected: "-1234.5"},
+		"european":   {format: NumberFormat{Decimal: ',', Thousands: '.'}, str: "1234567.89", expected: "1.234.567,89"},
+		"short":      {format: NumberFormat{Thousands: ','}, str: "123", expected: "123"},
+		"percent":    {format: NumberFormat{Percent: true}, str: "12.5", percent: true, expected: "12.5%"},
+		"currency":   {format: NumberFormat{Currency: "$"}, str: "-3.00", expected: "-$3.00"},
+		"accounting": {format: NumberFormat{Thousands: ',', Currency: "$", Accounting: true}, str: "-1234", expected: "($1,234)"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got := tc.format.format(tc.str, tc.percent)
+			if got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func testNumberFormatShiftDecimal(t *testing.T) {
+	tcs := []struct {
+		str      string
+		shift    int
+		expected string
+	}{
+		{str: "12", shift: -2, expected: "0.12"},
+		{str: "1.5", shift: -2, expected: "0.015"},
+		{str: "1234", shift: -2, expected: "12.34"},
+		{str: "0.123", shift: 2, expected: "12.3"},
+		{str: "0.001", shift: 2, expected: "0.1"},
+		{str: "2", shift: 2, expected: "200"},
+		{str: "0.12500", shift: 2, expected: "12.500"},
+		{str: "1e5", shift: 2, expected: "1e5"},
+	}
+
+	for _, tc := range tcs {
+		got := shiftDecimal(tc.str, tc.shift)
+		if got != tc.expected {
+			t.Errorf("%q shifted by %d: expected %q, got %q", tc.str, tc.shift, tc.expected, got)
+		}
+	}
+}
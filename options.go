@@ -1,5 +1,7 @@
 package goflat
 
+import "time"
+
 // Options is used to configure the marshalling and unmarshalling processes.
 type Options struct {
 	headersFromStruct bool
@@ -10,13 +12,74 @@ type Options struct {
 	// share the same `flat` tag value.
 	ErrorIfDuplicateHeaders bool
 	// ErrorIfMissingHeaders causes goflat to error out at unmarshalling time if
-	// a header has no struct field with a corresponding `flat` tag.
+	// a header has no struct field with a corresponding `flat` tag. Fields
+	// with the `required` tag option always cause an error, while fields with
+	// the `default` one never do. It also rejects the headers of expanded maps
+	// whose key cannot be parsed, which are otherwise left unbound.
 	ErrorIfMissingHeaders bool
+	// Headerless indicates that files have no header row: when unmarshalling
+	// the first row is treated as data, and when marshalling no header row is
+	// written. Fields are then bound by position, using either the `index`
+	// tag option or a name such as `flat:"#3"`, while the other fields fill
+	// the remaining positions in declaration order. To bind them by name
+	// instead, wrap the reader with [WithHeaders] and leave this unset.
+	Headerless bool
+	// HeaderNormalization is applied to both headers and `flat` tag names
+	// before matching them when unmarshalling. Marshalling always uses the
+	// names as they appear in the tags.
+	HeaderNormalization HeaderNormalization
 	// UnmarshalIgnoreEmpty causes the unmarshaller to skip any column which is
 	// an empty string. This is useful for instance if you have integer values
 	// and you are okay with empty string mapping to the zero value (0). For the
 	// same reason this will cause booleans to be false if the column is empty.
 	UnmarshalIgnoreEmpty bool
+	// NilValue is the value written for nil pointers, slices and maps when
+	// marshalling, and recognised as nil for pointer, slice and map fields
+	// when unmarshalling. Defaults to "nil". A non-nil pointer whose value
+	// is written as the nil value, such as a *string holding "nil", is
+	// therefore read back as nil. Slice items are quoted instead.
+	NilValue string
+	// EmptyAsNil causes nil pointers, slices and maps to be marshalled as empty
+	// strings, and empty columns to be unmarshalled as nil. [Options.NilValue]
+	// is still recognised when unmarshalling. Likewise, a non-nil pointer to
+	// an empty string is read back as nil.
+	EmptyAsNil bool
+	// PreserveUnsetFields causes [Decoder.Decode] not to zero the value
+	// before decoding a row into it, so that the fields without a column, or
+	// whose column is skipped because of [Options.UnmarshalIgnoreEmpty], keep
+	// their current value. Columns holding [Options.NilValue] still set
+	// pointer fields to nil, while the extra map, the expanded maps and the
+	// repeated fields only hold the columns of the last row.
+	PreserveUnsetFields bool
+	// CollectErrors causes the unmarshaller to skip rows which cannot be
+	// unmarshalled instead of aborting. The errors of the skipped rows are
+	// returned at the end as [RowErrors]. This has no effect on
+	// [UnmarshalToIterator], where the caller decides how to handle errors.
+	CollectErrors bool
+	// MaxErrors is the maximum number of errors tolerated when
+	// [Options.CollectErrors] is set, after which the unmarshaller aborts
+	// with [ErrTooManyErrors]. Zero means no limit.
+	MaxErrors int
+	// Workers is the number of goroutines converting rows concurrently, both
+	// when marshalling and unmarshalling. Rows are still read and written
+	// sequentially, in their original order, and at most a few hundred rows
+	// per worker are held in memory at any time. Values passed to the
+	// marshaller must not be modified afterwards. Values below 2 convert the
+	// rows on the caller's goroutine.
+	Workers int
+	// TimeLayout is the layout used for [time.Time] fields which do not
+	// specify one with the `layout` tag option. Defaults to [time.RFC3339Nano].
+	// [LayoutUnix] and [LayoutUnixMilli] can be used for epoch timestamps.
+	TimeLayout string
+	// TimeLocation is the location used to parse [time.Time] fields which do
+	// not carry timezone information, and to which times are converted when
+	// marshalling. Defaults to UTC when unmarshalling, while marshalled times
+	// keep their own location.
+	TimeLocation *time.Location
+	// NumberFormat is the format of integer and float fields, which can be
+	// overridden for single fields with tag options, see [NumberFormat].
+	// Defaults to the plain format of [strconv].
+	NumberFormat NumberFormat
 }
 
 // StrictOptions returns an [Options] struct with all options set to the strict
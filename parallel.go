@@ -0,0 +1,379 @@
+package goflat
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+	"sync"
+)
+
+const (
+	// batchSize is the number of rows handed to a worker at once, to keep the
+	// synchronisation cost low compared to unmarshalling.
+	batchSize = 64
+	// pendingPerWorker is the number of batches per worker which can be read
+	// ahead of the consumer.
+	pendingPerWorker = 2
+)
+
+// rowBatch is a batch of consecutive rows unmarshalled by a worker.
+type rowBatch[T any] struct {
+	records [][]string
+	lines   []lineFunc
+	values  []T
+	errs    []error
+	// readErr is the error which ended the batch, if any, and fatal is true
+	// if the iteration must end after it.
+	readErr error
+	fatal   bool
+	// done is closed once the worker is done with the batch.
+	done chan struct{}
+}
+
+func newRowBatch[T any]() *rowBatch[T] {
+	return &rowBatch[T]{
+		records: make([][]string, 0, batchSize),
+		lines:   make([]lineFunc, 0, batchSize),
+		done:    make(chan struct{}),
+	}
+}
+
+func (b *rowBatch[T]) unmarshal(factory *structFactory[T]) {
+	defer close(b.done)
+
+	b.values = make([]T, len(b.records))
+	b.errs = make([]error, len(b.records))
+
+	for i, record := range b.records {
+		value, err := factory.unmarshal(record)
+		if err != nil {
+			b.errs[i] = withLine(err, b.lines[i])
+
+			continue
+		}
+
+		b.values[i] = value
+	}
+}
+
+// yield passes the results of the batch to the consumer, returning false if
+// the iteration must end.
+func (b *rowBatch[T]) yield(ctx context.Context, yield func(T, error) bool) bool {
+	var zero T
+
+	for i, err := range b.errs {
+		if ctx.Err() != nil {
+			yield(zero, context.Cause(ctx))
+
+			return false
+		}
+
+		if err != nil {
+			if !yield(zero, err) {
+				return false
+			}
+
+			continue
+		}
+
+		if !yield(b.values[i], nil) {
+			return false
+		}
+	}
+
+	if b.readErr != nil {
+		return yield(zero, b.readErr) && !b.fatal
+	}
+
+	return true
+}
+
+// unmarshalParallel works like the loop of [UnmarshalToIterator] but
+// unmarshals the rows on the given number of workers. Rows are read on a
+// single goroutine and queued in batches, in order, so that the consumer can
+// wait for each of them in turn. All the goroutines have returned by the time
+// it returns.
+func unmarshalParallel[T any](ctx context.Context, reader RowReader, factory *structFactory[T], workers int, yield func(T, error) bool) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan *rowBatch[T])
+	pending := make(chan *rowBatch[T], workers*pendingPerWorker)
+
+	for range workers {
+		wg.Go(func() {
+			for batch := range jobs {
+				batch.unmarshal(factory)
+			}
+		})
+	}
+
+	wg.Go(func() {
+		defer close(pending)
+		defer close(jobs)
+
+		readBatches(ctx, reader, jobs, pending)
+	})
+
+	var zero T
+
+	for {
+		if ctx.Err() != nil {
+			yield(zero, context.Cause(ctx))
+
+			return
+		}
+
+		var (
+			batch *rowBatch[T]
+			ok    bool
+		)
+
+		select {
+		case <-ctx.Done():
+			yield(zero, context.Cause(ctx))
+
+			return
+		case batch, ok = <-pending:
+			if !ok {
+				return
+			}
+		}
+
+		// Queued batches have been taken by a worker, so this cannot block
+		// forever.
+		<-batch.done
+
+		if !batch.yield(ctx, yield) {
+			return
+		}
+	}
+}
+
+// readBatches reads the rows and hands them to the workers in batches,
+// queueing them in the same order for the consumer. A read error ends the
+// batch it occurs in.
+func readBatches[T any](ctx context.Context, reader RowReader, jobs, pending chan<- *rowBatch[T]) {
+	for ctx.Err() == nil {
+		batch := newRowBatch[T]()
+		last := readBatch(reader, batch)
+
+		if len(batch.records) == 0 && batch.readErr == nil {
+			return
+		}
+
+		if !send(ctx, jobs, batch) || !send(ctx, pending, batch) || last {
+			return
+		}
+	}
+}
+
+// readBatch fills the batch, returning true if there are no more rows to
+// read.
+func readBatch[T any](reader RowReader, batch *rowBatch[T]) bool {
+	for len(batch.records) < batchSize {
+		record, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return true
+			}
+
+			// Only errors about the content of the file are recoverable.
+			var parseErr *csv.ParseError
+
+			batch.readErr = fmt.Errorf("read row: %w", err)
+			batch.fatal = !errors.As(err, &parseErr)
+
+			return batch.fatal
+		}
+
+		// The batch is parsed after the next rows are read, which may
+		// overwrite the record, see [csv.Reader.ReuseRecord].
+		batch.records = append(batch.records, slices.Clone(record))
+		batch.lines = append(batch.lines, snapshotLines(reader, len(record)))
+	}
+
+	return false
+}
+
+// send sends the value to the channel unless the context is done first.
+func send[V any](ctx context.Context, ch chan<- V, value V) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case ch <- value:
+		return true
+	}
+}
+
+// snapshotLines works like [readerLines] but the result stays valid after
+// the reader moves on to the next row.
+func snapshotLines(reader RowReader, fields int) lineFunc {
+	lines := readerLines(reader)
+	if lines == nil || fields == 0 {
+		return nil
+	}
+
+	first, last := lines(0), lines(fields-1)
+	if first == last {
+		// The common case of a row on a single line.
+		return func(int) int { return first }
+	}
+
+	fieldLines := make([]int, fields)
+	for i := range fieldLines {
+		fieldLines[i] = lines(i)
+	}
+
+	return func(field int) int { return fieldLines[field] }
+}
+
+// valueBatch is a batch of consecutive values marshalled by a worker.
+type valueBatch[T any] struct {
+	// row is the index of the first value.
+	row     int
+	values  []T
+	records [][]string
+	// err is the error which ended the batch, if any.
+	err error
+	// done is closed once the worker is done with the batch.
+	done chan struct{}
+}
+
+func newValueBatch[T any](row int) *valueBatch[T] {
+	return &valueBatch[T]{
+		row:    row,
+		values: make([]T, 0, batchSize),
+		done:   make(chan struct{}),
+	}
+}
+
+func (b *valueBatch[T]) marshal(factory *structFactory[T]) {
+	defer close(b.done)
+
+	b.records = make([][]string, 0, len(b.values))
+
+	for i := range b.values {
+		record, err := factory.marshal(&b.values[i])
+		if err != nil {
+			b.err = marshalRowError(err, b.row+i)
+
+			return
+		}
+
+		b.records = append(b.records, record)
+	}
+}
+
+// marshalParallel works like [marshalRecords] but marshals the values on the
+// given number of workers. The values are collected in batches on the
+// caller's goroutine, which also yields the records of the oldest batches
+// as soon as they are done, so that at most a few batches per worker are in
+// memory. All the workers have returned by the time the iteration ends.
+//
+//nolint:cyclop // Fine here.
+func marshalParallel[T any](ctx context.Context, seq iter.Seq[T], factory *structFactory[T], workers int, first func(T) error) iter.Seq2[[]string, error] {
+	return func(yield func([]string, error) bool) {
+		var wg sync.WaitGroup
+		defer wg.Wait()
+
+		jobs := make(chan *valueBatch[T])
+		defer close(jobs)
+
+		for range workers {
+			wg.Go(func() {
+				for batch := range jobs {
+					batch.marshal(factory)
+				}
+			})
+		}
+
+		// queue holds the batches handed to the workers, in order.
+		var queue []*valueBatch[T]
+
+		// drain yields the records of the queued batches until only keep are
+		// left and the oldest is not done yet. It returns false if the
+		// iteration must end.
+		drain := func(keep int) bool {
+			for len(queue) > 0 {
+				batch := queue[0]
+
+				if len(queue) <= keep {
+					select {
+					case <-batch.done:
+					default:
+						return true
+					}
+				}
+
+				<-batch.done
+
+				queue = queue[1:]
+
+				for _, record := range batch.records {
+					if !yield(record, nil) {
+						return false
+					}
+				}
+
+				if batch.err != nil {
+					yield(nil, batch.err)
+
+					return false
+				}
+			}
+
+			return true
+		}
+
+		batch := newValueBatch[T](0)
+
+		dispatch := func() bool {
+			if !send(ctx, jobs, batch) {
+				yield(nil, context.Cause(ctx))
+
+				return false
+			}
+
+			queue = append(queue, batch)
+			batch = newValueBatch[T](batch.row + len(batch.values))
+
+			return drain(workers * pendingPerWorker)
+		}
+
+		for value := range seq {
+			if ctx.Err() != nil {
+				yield(nil, context.Cause(ctx))
+
+				return
+			}
+
+			if batch.row == 0 && len(batch.values) == 0 {
+				err := first(value)
+				if err != nil {
+					yield(nil, err)
+
+					return
+				}
+			}
+
+			batch.values = append(batch.values, value)
+			if len(batch.values) == batchSize && !dispatch() {
+				return
+			}
+		}
+
+		if len(batch.values) > 0 && !dispatch() {
+			return
+		}
+
+		drain(0)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 )
 
@@ -65,3 +66,91 @@ func DetectReader(reader io.Reader) (*csv.Reader, error) {
 
 	return csvReader, nil
 }
+
+// WithHeaders returns a reader which yields the given headers before the rows
+// of the given reader, so that the columns of a file without a header row can
+// still be matched by name. [Options.Headerless] must not be set, which
+// causes [ErrHeaderlessWithHeaders], and [FixedWidthReader] already yields its
+// own headers.
+func WithHeaders(reader RowReader, headers ...string) RowReader { //nolint:ireturn // Depends on the reader.
+	headerReader := &headerReader{reader: reader, headers: headers}
+
+	if positioner, ok := reader.(fieldPositioner); ok {
+		return &positionedHeaderReader{headerReader: headerReader, positioner: positioner}
+	}
+
+	return headerReader
+}
+
+// headerReader is returned by [WithHeaders].
+type headerReader struct {
+	reader  RowReader
+	headers []string
+	// inHeaders is true while the last row returned is the headers.
+	inHeaders bool
+	started   bool
+}
+
+func (r *headerReader) Read() ([]string, error) {
+	if !r.started {
+		r.started = true
+		r.inHeaders = true
+
+		return slices.Clone(r.headers), nil
+	}
+
+	r.inHeaders = false
+
+	return r.reader.Read() //nolint:wrapcheck // Same as the underlying reader.
+}
+
+// positionedHeaderReader is returned by [WithHeaders] for readers which can
+// report the position of the fields, see [fieldPositioner].
+type positionedHeaderReader struct {
+	*headerReader
+
+	positioner fieldPositioner
+}
+
+// FieldPos returns the position of the given field of the last row read
+// from the underlying reader. The headers have no position.
+func (r *positionedHeaderReader) FieldPos(field int) (int, int) {
+	if r.inHeaders {
+		return 0, 0
+	}
+
+	return r.positioner.FieldPos(field)
+}
+
+// checkReader returns an error if the headers yielded by the reader
+// contradict the options: [ErrFixedWidthHeaders] if it reads a fixed-width
+// file whose header row would be skipped or preceded by another one, and
+// [ErrHeaderlessWithHeaders] if it is wrapped with [WithHeaders] while
+// [Options.Headerless] is set, which would read the headers as data.
+func checkReader(reader RowReader, opts Options) error {
+	wrapped := false
+
+	switch headerReader := reader.(type) {
+	case *headerReader:
+		reader, wrapped = headerReader.reader, true
+	case *positionedHeaderReader:
+		reader, wrapped = headerReader.reader, true
+	}
+
+	if _, ok := reader.(*FixedWidthReader); ok {
+		switch {
+		case wrapped:
+			return fmt.Errorf("fixed-width reader wrapped with headers: %w", ErrFixedWidthHeaders)
+		case opts.Headerless:
+			return fmt.Errorf("headerless fixed-width reader: %w", ErrFixedWidthHeaders)
+		}
+
+		return nil
+	}
+
+	if wrapped && opts.Headerless {
+		return fmt.Errorf("headerless reader wrapped with headers: %w", ErrHeaderlessWithHeaders)
+	}
+
+	return nil
+}
@@ -1,36 +1,129 @@
 package goflat
 
 import (
+	"cmp"
+	"encoding"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type structFactory[T any] struct {
 	structType reflect.Type
 	pointer    bool
+	headers    []string
 	columnMap  map[int]int
 	columns    []*columnDescriptor
-	options    Options
+	// defaultColumns lists the columns which have no header but have a
+	// default value.
+	defaultColumns []int
+	// extra is the catch-all map field for unmapped columns, if any.
+	extra *columnDescriptor
+	// extraKeys are the keys of the extra map which are written as additional
+	// columns when marshalling.
+	extraKeys []string
+	// repeated are the slice fields spread across numbered columns, see
+	// [repeatedField]. repeatedMap binds headers to their items, while
+	// itemCounts holds the number of items of each field written when
+	// marshalling.
+	repeated    []*repeatedField
+	repeatedMap map[int]repeatedBinding
+	itemCounts  []int
+	// expanded are the map fields spread across one column per key, see
+	// [expandedField]. expandedMap binds headers to their keys, while
+	// expandedKeys holds the keys of each map written when marshalling.
+	expanded     []*expandedField
+	expandedMap  map[int]expandedBinding
+	expandedKeys [][]string
+	// pointerStructs are the paths to the nested structs which are pointers.
+	// nilGroups holds the columns of each of them found in the headers, see
+	// [nilGroup], while columnNilGroups lists the groups of each column of
+	// the record.
+	pointerStructs  [][]int
+	nilGroups       []nilGroup
+	columnNilGroups map[int][]int
+	// positions holds the index of each column in the marshalled row, which
+	// is width cells long before the extra columns.
+	positions []int
+	width     int
+	// flatMarshal and flatUnmarshal are true if the struct has code generated
+	// by goflat-gen.
+	flatMarshal   bool
+	flatUnmarshal bool
+	options       Options
 }
 
 type columnDescriptor struct {
-	name        string
-	value       any
+	name string
+	// aliases are alternative names which are accepted when unmarshalling.
+	aliases []string
+	// position is the index of the column in the row, only meaningful if
+	// positional is true.
+	position   int
+	positional bool
+	// fieldName is the name of the Go field, including the names of the
+	// nested structs containing it.
+	fieldName   string
 	reflectType reflect.Type
+	// decode and encode convert the column from and to a string, see
+	// [columnDescriptor.compile].
+	decode decodeFunc
+	encode encodeFunc
+	// decodePtr and encodePtr are used with the code generated by
+	// goflat-gen, see [FlatMarshaller].
+	decodePtr ptrDecodeFunc
+	encodePtr ptrEncodeFunc
+	// index is the path to the field starting from the root struct, as used
+	// by [reflect.Value.FieldByIndex].
+	index []int
+	// nullable is true if the field is a pointer, a slice or a map.
+	nullable bool
+	// layout and location are only used by [time.Time] fields.
+	layout   string
+	location *time.Location
+	// required, defaultValue and omitEmpty are set by the tag options with
+	// the same name.
+	required     bool
+	defaultValue *string
+	omitEmpty    bool
+	// fixedWidth is the layout of the column in fixed-width files, nil if the
+	// field has neither the pos nor the width tag options.
+	fixedWidth *fixedWidthField
+	// slice and mapFormat are the formats of slice and map fields, see
+	// [sliceFormat] and [mapFormat].
+	slice     sliceFormat
+	mapFormat mapFormat
+	// json is set by the tag option with the same name: the column holds
+	// the field encoded as JSON, regardless of its type.
+	json bool
+	// number is the format of integers and floats, see [NumberFormat].
+	number NumberFormat
 }
 
 // FieldTag is the tag that must be used in the struct fields so that goflat can
 // work with them.
 const FieldTag = "flat"
 
+// defaultNilValue is used to represent nil pointers when [Options.NilValue]
+// is not set.
+const defaultNilValue = "nil"
+
+// positionPrefix marks a column name as a position, e.g. `flat:"#3"`.
+const positionPrefix = "#"
+
+// nestedSeparator is used to join the tag of a nested struct field with the
+// tags of its own fields.
+const nestedSeparator = "."
+
 //nolint:varnamelen,cyclop,gocyclo // Fine-ish here.
 func newFactory[T any](headers []string, options Options) (*structFactory[T], error) {
 	var v T
 
 	t := reflect.TypeOf(v)
-	rv := reflect.ValueOf(v)
 
 	pointer := false
 
@@ -40,68 +133,137 @@ func newFactory[T any](headers []string, options Options) (*structFactory[T], er
 	case reflect.Pointer:
 		pointer = true
 		t = t.Elem()
-		rv = reflect.New(t).Elem()
+
+		if t.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("type %T: %w", v, ErrNotAStruct)
+		}
 	default:
 		return nil, fmt.Errorf("type %T: %w", v, ErrNotAStruct)
 	}
 
+	info, err := loadStructInfo(t, options)
+	if err != nil {
+		return nil, err
+	}
+
 	factory := &structFactory[T]{
-		structType: t,
-		pointer:    pointer,
-		columnMap:  make(map[int]int, len(headers)),
-		columns:    make([]*columnDescriptor, t.NumField()),
-		options:    options,
+		structType:     t,
+		pointer:        pointer,
+		headers:        headers,
+		columnMap:      make(map[int]int, len(headers)),
+		columns:        info.columns,
+		extra:          info.extra,
+		repeated:       info.repeated,
+		expanded:       info.expanded,
+		pointerStructs: info.pointerStructs,
+		positions:      info.positions,
+		width:          info.width,
+		flatMarshal:    info.flatMarshal,
+		flatUnmarshal:  info.flatUnmarshal,
+		options:        options,
 	}
 
-	covered := make([]bool, len(headers))
+	if options.headersFromStruct {
+		return factory, nil
+	}
 
-	for i := range t.NumField() {
-		fieldT := t.Field(i)
-		fieldV := rv.Field(i)
+	err = factory.bindHeaders()
+	if err != nil {
+		return nil, err
+	}
+
+	return factory, nil
+}
+
+// layoutPositions computes the position of each column when marshalling.
+// Positional columns are placed at their index, while the others fill the
+// remaining gaps in order.
+func (s *structInfo) layoutPositions() error {
+	taken := map[int]string{}
+
+	for _, column := range s.columns {
+		if !column.positional {
+			continue
+		}
+
+		if other, ok := taken[column.position]; ok {
+			return fmt.Errorf("fields %q and %q, index %d: %w", other, column.fieldName, column.position, ErrInvalidTag)
+		}
+
+		taken[column.position] = column.fieldName
+	}
+
+	s.positions = make([]int, len(s.columns))
+	s.width = 0
+	next := 0
+
+	for i, column := range s.columns {
+		position := column.position
+
+		if !column.positional {
+			for taken[next] != "" {
+				next++
+			}
 
-		v, ok := fieldT.Tag.Lookup(FieldTag)
-		if !ok && options.ErrorIfTaglessField {
-			return nil, fmt.Errorf("field %q breaks strict mode: %w", fieldT.Name, ErrTaglessField)
+			position = next
+			next++
 		}
 
-		factory.columns[i] = &columnDescriptor{
-			name:        v,
-			value:       fieldV.Interface(),
-			reflectType: fieldT.Type,
+		s.positions[i] = position
+		s.width = max(s.width, position+1)
+	}
+
+	return nil
+}
+
+// bindHeaders maps the headers to the columns, first by position and then by
+// name. With [Options.Headerless], the other columns are bound to the
+// positions they are marshalled at, see [structInfo.layoutPositions].
+//
+//nolint:cyclop // Fine-ish here.
+func (s *structFactory[T]) bindHeaders() error {
+	covered := make([]bool, len(s.headers))
+
+	for i, column := range s.columns {
+		if !column.positional {
+			continue
 		}
 
-		//nolint:exhaustive // Fine here.
-		switch fieldT.Type.Kind() {
-		case reflect.Slice:
-			factory.columns[i].value = reflect.New(fieldV.Type().Elem()).Elem().Interface()
-		case reflect.Pointer:
-			factory.columns[i].value = reflect.Zero(fieldV.Type().Elem()).Interface()
+		s.columnMap[column.position] = i
+
+		if column.position < len(covered) {
+			covered[column.position] = true
 		}
+	}
 
-		if v == "" || v == "-" {
-			factory.columns[i].name = ""
+	normalizedHeaders := normalizeHeaders(s.headers, s.options.HeaderNormalization)
 
+	for i, column := range s.columns {
+		if column.positional {
 			continue
 		}
 
-		if options.headersFromStruct {
+		if s.options.Headerless {
+			s.columnMap[s.positions[i]] = i
+
 			continue
 		}
 
 		handledAt := -1
+		names := column.normalizedNames(s.options.HeaderNormalization)
 
-		for j, header := range headers {
+		for j, header := range normalizedHeaders {
 			if covered[j] {
 				continue
 			}
 
-			if header != v {
+			if !slices.Contains(names, header) {
 				continue
 			}
 
 			if handledAt >= 0 {
-				if options.ErrorIfDuplicateHeaders {
-					return nil, fmt.Errorf("header %q, index %d and %d: %w", header, j, handledAt, ErrDuplicatedHeader)
+				if s.options.ErrorIfDuplicateHeaders {
+					return fmt.Errorf("header %q, index %d and %d: %w", s.headers[j], j, handledAt, ErrDuplicatedHeader)
 				}
 
 				continue
@@ -109,195 +271,780 @@ func newFactory[T any](headers []string, options Options) (*structFactory[T], er
 
 			handledAt = j
 			covered[j] = true
-			factory.columnMap[j] = i
+			s.columnMap[j] = i
+		}
+
+		if handledAt >= 0 {
+			continue
 		}
 
-		if handledAt == -1 && options.ErrorIfMissingHeaders {
-			return nil, fmt.Errorf("header %q: %w", v, ErrMissingHeader)
+		switch {
+		case column.required:
+			return fmt.Errorf("header %q of required field: %w", column.name, ErrMissingHeader)
+		case column.defaultValue != nil:
+			s.defaultColumns = append(s.defaultColumns, i)
+		case s.options.ErrorIfMissingHeaders:
+			return fmt.Errorf("header %q: %w", column.name, ErrMissingHeader)
 		}
 	}
 
-	return factory, nil
+	if len(s.repeated) > 0 {
+		err := s.bindRepeated(normalizedHeaders, covered)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(s.expanded) > 0 {
+		err := s.bindExpanded(normalizedHeaders, covered)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.bindNilGroups()
+
+	return nil
 }
 
-//nolint:varnamelen,ireturn // Fine for now.
+// fieldPath describes the position of a (nested) struct within the root one.
+type fieldPath struct {
+	index []int
+	// prefixes are prepended to the column names, the first one is used for
+	// the canonical name while the others produce aliases.
+	prefixes []string
+	// fieldPrefix is prepended to the Go field names.
+	fieldPrefix string
+}
+
+// collectColumns walks the fields of the given struct type and appends a
+// column for each of them. Nested structs are flattened recursively, with
+// their tag used as a prefix for the columns of their fields, while anonymous
+// embedded structs are promoted without a prefix.
+//
+//nolint:cyclop // Fine-ish here.
+func (s *structInfo) collectColumns(t reflect.Type, path fieldPath, visiting map[reflect.Type]bool) error {
+	if visiting[t] {
+		return fmt.Errorf("type %s is recursive: %w", t, ErrUnsupportedType)
+	}
+
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := range t.NumField() {
+		fieldT := t.Field(i)
+
+		// Unexported fields cannot be set, unless they are embedded structs
+		// whose exported fields are promoted.
+		if !fieldT.IsExported() && !(fieldT.Anonymous && fieldT.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		tag, ok := fieldT.Tag.Lookup(FieldTag)
+		if tag == "-" {
+			continue
+		}
+
+		v, tagOpts, err := parseTag(tag)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", fieldT.Name, err)
+		}
+
+		fieldIndex := append(slices.Clone(path.index), i)
+
+		// Structs with the json tag option are a single column.
+		if isNestedStruct(fieldT.Type) && !tagOpts.has("json") {
+			if !ok && !fieldT.Anonymous && s.key.errorIfTaglessField {
+				return fmt.Errorf("field %q breaks strict mode: %w", fieldT.Name, ErrTaglessField)
+			}
+
+			nestedPath := fieldPath{
+				index:       fieldIndex,
+				prefixes:    path.prefixes,
+				fieldPrefix: path.fieldPrefix + fieldT.Name + nestedSeparator,
+			}
+
+			switch {
+			case v != "":
+				nestedPath.prefixes = joinNames(path.prefixes, v, nestedSeparator)
+			case !fieldT.Anonymous:
+				continue
+			}
+
+			nestedT := fieldT.Type
+			if nestedT.Kind() == reflect.Pointer {
+				nestedT = nestedT.Elem()
+				s.pointerStructs = append(s.pointerStructs, fieldIndex)
+			}
+
+			err = s.collectColumns(nestedT, nestedPath, visiting)
+			if err != nil {
+				return fmt.Errorf("field %q: %w", fieldT.Name, err)
+			}
+
+			continue
+		}
+
+		if !fieldT.IsExported() {
+			continue
+		}
+
+		if !ok && s.key.errorIfTaglessField {
+			return fmt.Errorf("field %q breaks strict mode: %w", fieldT.Name, ErrTaglessField)
+		}
+
+		if tagOpts.has("extra") {
+			err = s.setExtra(fieldT, fieldIndex, path)
+			if err != nil {
+				return fmt.Errorf("field %q: %w", fieldT.Name, err)
+			}
+
+			continue
+		}
+
+		if tagOpts.has("expand") {
+			err = s.addExpanded(fieldT, fieldIndex, path, v, tagOpts)
+			if err != nil {
+				return fmt.Errorf("field %q: %w", fieldT.Name, err)
+			}
+
+			continue
+		}
+
+		position, positional, err := parsePosition(&v, tagOpts)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", fieldT.Name, err)
+		}
+
+		if v == "" && !positional {
+			continue
+		}
+
+		if strings.Contains(v, repeatedPlaceholder) {
+			err = s.addRepeated(fieldT, fieldIndex, path, v, tagOpts, visiting)
+			if err != nil {
+				return fmt.Errorf("field %q: %w", fieldT.Name, err)
+			}
+
+			continue
+		}
+
+		names := []string{""}
+		if v != "" {
+			names = joinNames(path.prefixes, v, "")
+		}
+
+		column, err := s.newColumn(names, fieldT.Type, tagOpts)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", fieldT.Name, err)
+		}
+
+		column.position = position
+		column.positional = positional
+		column.fieldName = path.fieldPrefix + fieldT.Name
+		column.index = fieldIndex
+
+		s.columns = append(s.columns, column)
+	}
+
+	return nil
+}
+
+// newColumn returns the column of a field of the given type, named after the
+// first of the given names and configured by the tag options. The caller sets
+// the position and the location of the field.
+func (s *structInfo) newColumn(names []string, t reflect.Type, tagOpts tagOptions) (*columnDescriptor, error) {
+	column := &columnDescriptor{
+		name:        names[0],
+		aliases:     names[1:],
+		reflectType: t,
+		nullable:    isNillable(t),
+		layout:      cmp.Or(tagOpts["layout"], s.key.timeLayout, defaultTimeLayout),
+		location:    s.key.timeLocation,
+		required:    tagOpts.has("required"),
+		omitEmpty:   tagOpts.has("omitempty"),
+		json:        tagOpts.has("json"),
+	}
+
+	for _, option := range append([]string{"sep", "brackets", "kvsep", "layout"}, numberOptions...) {
+		if column.json && tagOpts.has(option) {
+			return nil, fmt.Errorf("options json and %q: %w", option, ErrInvalidTag)
+		}
+	}
+
+	var err error
+
+	column.fixedWidth, err = parseFixedWidth(tagOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	column.slice, err = parseSliceFormat(tagOpts, t)
+	if err != nil {
+		return nil, err
+	}
+
+	column.slice.nilValue = s.key.nilValue
+
+	column.mapFormat, err = parseMapFormat(tagOpts, t)
+	if err != nil {
+		return nil, err
+	}
+
+	column.number, err = parseNumberFormat(s.key.numberFormat, tagOpts, t)
+	if err != nil {
+		return nil, err
+	}
+
+	column.compile()
+
+	if defaultValue, ok := tagOpts["default"]; ok {
+		err = column.setValue(reflect.New(column.reflectType).Elem(), defaultValue)
+		if err != nil {
+			return nil, fmt.Errorf("default %q: %w: %w", defaultValue, ErrInvalidTag, err)
+		}
+
+		column.defaultValue = &defaultValue
+	}
+
+	return column, nil
+}
+
+// setExtra sets the given field as the catch-all map for unmapped columns.
+func (s *structInfo) setExtra(fieldT reflect.StructField, fieldIndex []int, path fieldPath) error {
+	if s.extra != nil {
+		return fmt.Errorf("more than one extra field: %w", ErrInvalidTag)
+	}
+
+	t := fieldT.Type
+	if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String || t.Elem().Kind() != reflect.String {
+		return fmt.Errorf("extra field of type %s is not a map of strings: %w", t, ErrUnsupportedType)
+	}
+
+	s.extra = &columnDescriptor{
+		fieldName:   path.fieldPrefix + fieldT.Name,
+		reflectType: t,
+		index:       fieldIndex,
+	}
+
+	return nil
+}
+
+// parsePosition returns the position of the column, either from a name in the
+// form "#3" (which is then cleared) or from the index tag option.
+func parsePosition(name *string, tagOpts tagOptions) (int, bool, error) {
+	positionStr, ok := tagOpts["index"]
+
+	if strings.HasPrefix(*name, positionPrefix) {
+		positionStr, ok = strings.TrimPrefix(*name, positionPrefix), true
+		*name = ""
+	}
+
+	if !ok {
+		return 0, false, nil
+	}
+
+	position, err := strconv.Atoi(positionStr)
+	if err != nil || position < 0 {
+		return 0, false, fmt.Errorf("index %q: %w", positionStr, ErrInvalidTag)
+	}
+
+	return position, true, nil
+}
+
+// joinNames returns all the combinations of the given prefixes with the
+// aliases in the tag name, each followed by the separator. The canonical name
+// comes first.
+func joinNames(prefixes []string, tagName, separator string) []string {
+	aliases := strings.Split(tagName, aliasSeparator)
+	names := make([]string, 0, len(prefixes)*len(aliases))
+
+	for _, prefix := range prefixes {
+		for _, alias := range aliases {
+			names = append(names, prefix+alias+separator)
+		}
+	}
+
+	return names
+}
+
+// normalizedNames returns the name and aliases of the column, normalized for
+// matching against the headers.
+func (c *columnDescriptor) normalizedNames(normalization HeaderNormalization) []string {
+	names := make([]string, 0, 1+len(c.aliases))
+
+	for _, name := range append([]string{c.name}, c.aliases...) {
+		names = append(names, normalizeHeader(name, normalization))
+	}
+
+	return names
+}
+
+// isNestedStruct returns whether the given type is a struct (or a pointer to a
+// struct) which must be flattened into multiple columns. Structs with custom
+// conversion logic are treated as a single column.
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	pt := reflect.PointerTo(t)
+
+	for _, iface := range []reflect.Type{marshallerType, unmarshallerType, textMarshalerType, textUnmarshalerType} {
+		if t.Implements(iface) || pt.Implements(iface) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//nolint:gochecknoglobals // Used for type checking.
+var (
+	marshallerType      = reflect.TypeFor[Marshaller]()
+	unmarshallerType    = reflect.TypeFor[Unmarshaller]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
 func (s *structFactory[T]) unmarshal(record []string) (T, error) {
-	var zero T
+	var zero, result T
+
+	// Decoding straight into the result avoids copying the struct when
+	// returning it.
+	err := s.unmarshalInto(&result, record)
+	if err != nil {
+		return zero, err
+	}
+
+	return result, nil
+}
+
+// unmarshalInto sets the fields of the target from the record. Fields
+// without a column are left untouched and, if T is a pointer, a nil target
+// is allocated.
+//
+//nolint:varnamelen,ireturn // Fine for now.
+func (s *structFactory[T]) unmarshalInto(target *T, record []string) error {
+	newStruct := reflect.ValueOf(target).Elem()
+	if s.pointer {
+		if newStruct.IsNil() {
+			newStruct.Set(reflect.New(s.structType))
+		}
+
+		newStruct = newStruct.Elem()
+	}
+
+	flat := s.flatUnmarshaller(newStruct)
 
-	newStruct := reflect.New(s.structType).Elem()
+	if s.extra != nil && s.options.PreserveUnsetFields {
+		// The extra columns are those of the row only.
+		extraMap := fieldByIndex(newStruct, s.extra.index)
+		if extraMap.IsValid() {
+			extraMap.Clear()
+		}
+	}
+
+	if s.options.PreserveUnsetFields {
+		s.clearRepeated(newStruct)
+		s.clearExpanded(newStruct)
+	}
 
 	for i, column := range record {
 		mappedIndex, found := s.columnMap[i]
 		if !found {
+			if binding, ok := s.repeatedMap[i]; ok {
+				err := s.setRepeated(newStruct, binding, column, s.inNilStruct(record, i))
+				if err != nil {
+					return &ParseError{
+						Column: i,
+						Header: s.header(i),
+						Field:  s.repeated[binding.field].itemName(binding.item, binding.column),
+						Value:  column,
+						Err:    err,
+					}
+				}
+
+				continue
+			}
+
+			if binding, ok := s.expandedMap[i]; ok {
+				err := s.setExpanded(newStruct, binding, column)
+				if err != nil {
+					return &ParseError{
+						Column: i,
+						Header: s.header(i),
+						Field:  s.expanded[binding.field].fieldName,
+						Value:  column,
+						Err:    err,
+					}
+				}
+
+				continue
+			}
+
+			if i < len(s.headers) {
+				s.setExtraColumn(newStruct, s.headers[i], column)
+			}
+
 			continue
 		}
 
-		if column == "" && s.options.UnmarshalIgnoreEmpty {
+		columnDescriptor := s.columns[mappedIndex]
+
+		if s.inNilStruct(record, i) {
 			continue
 		}
 
+		var err error
+
+		switch {
+		case column != "":
+		case columnDescriptor.required:
+			err = ErrMissingValue
+		case columnDescriptor.defaultValue != nil:
+			column = *columnDescriptor.defaultValue
+		}
+
+		if err == nil {
+			err = s.setColumn(newStruct, flat, mappedIndex, column)
+		}
+
+		if err != nil {
+			return &ParseError{
+				Column: i,
+				Header: s.header(i),
+				Field:  columnDescriptor.fieldName,
+				Value:  column,
+				Err:    err,
+			}
+		}
+	}
+
+	for _, mappedIndex := range s.defaultColumns {
 		columnDescriptor := s.columns[mappedIndex]
 
-		value, err := columnDescriptor.parseColumn(column)
+		err := s.setColumn(newStruct, flat, mappedIndex, *columnDescriptor.defaultValue)
 		if err != nil {
-			return zero, fmt.Errorf("parse column %d: %w", i, err)
+			return fmt.Errorf("default of field %s: %w", columnDescriptor.fieldName, err)
 		}
+	}
+
+	s.clearNilStructs(newStruct, record)
 
-		if columnDescriptor.reflectType.Kind() == reflect.Pointer {
-			value = ptr(value)
+	return nil
+}
+
+// reset zeroes the target before unmarshalling into it. If T is a pointer,
+// the struct it points to is zeroed instead, to reuse it.
+func (s *structFactory[T]) reset(target *T) {
+	if s.pointer {
+		value := reflect.ValueOf(target).Elem()
+		if !value.IsNil() {
+			value.Elem().SetZero()
+
+			return
 		}
+	}
+
+	var zero T
+
+	*target = zero
+}
 
-		newStruct.Field(mappedIndex).Set(reflect.ValueOf(value))
+// header returns the header at the given index, if known.
+func (s *structFactory[T]) header(i int) string {
+	if i < len(s.headers) {
+		return s.headers[i]
 	}
 
-	if s.pointer {
-		newStruct = newStruct.Addr()
+	return ""
+}
+
+// setExtraColumn adds an unmapped column to the extra map, if any.
+func (s *structFactory[T]) setExtraColumn(newStruct reflect.Value, header, column string) {
+	if s.extra == nil || (column == "" && s.options.UnmarshalIgnoreEmpty) {
+		return
 	}
 
-	return newStruct.Interface().(T), nil //nolint:forcetypeassert // Safe here.
+	extraMap := fieldByIndexAlloc(newStruct, s.extra.index)
+	if extraMap.IsNil() {
+		extraMap.Set(reflect.MakeMap(s.extra.reflectType))
+	}
+
+	extraMap.SetMapIndex(
+		reflect.ValueOf(header).Convert(s.extra.reflectType.Key()),
+		reflect.ValueOf(column).Convert(s.extra.reflectType.Elem()),
+	)
 }
 
-// we need to do this because otherwise we get strange behaviour with interface
-// pointers.
-func ptr(v any) any {
-	rv := reflect.ValueOf(v)
-	pt := reflect.PointerTo(rv.Type())
-	pv := reflect.New(pt.Elem())
-	pv.Elem().Set(rv)
+// setColumn parses the given column and sets it into the field described by
+// the column descriptor at the given index, using the generated code if any.
+func (s *structFactory[T]) setColumn(newStruct reflect.Value, flat FlatUnmarshaller, mappedIndex int, column string) error {
+	columnDescriptor := s.columns[mappedIndex]
+
+	if columnDescriptor.nullable && s.isNil(column) {
+		if s.options.PreserveUnsetFields {
+			// The field may hold the value of a previous row.
+			field := fieldByIndex(newStruct, columnDescriptor.index)
+			if field.IsValid() {
+				field.SetZero()
+			}
+		}
+
+		return nil
+	}
+
+	if column == "" && s.options.UnmarshalIgnoreEmpty {
+		return nil
+	}
+
+	var err error
+
+	if flat != nil {
+		err = columnDescriptor.decodePtr(column, flat.UnmarshalFlat(mappedIndex))
+	} else {
+		err = columnDescriptor.setValue(fieldByIndexAlloc(newStruct, columnDescriptor.index), column)
+	}
 
-	return pv.Interface()
+	if err != nil {
+		return fmt.Errorf("parse string %q: %w", column, err)
+	}
+
+	return nil
 }
 
-//nolint:varnamelen // Fine for now.
-func (c *columnDescriptor) parseColumn(column string) (any, error) {
-	if c.reflectType.Kind() == reflect.Slice {
-		column = strings.Trim(column, "[]{}")
-		values := strings.Split(column, ",")
-		slice := reflect.MakeSlice(c.reflectType, len(values), len(values))
+// isNil returns whether the given column represents a nil pointer.
+func (s *structFactory[T]) isNil(column string) bool {
+	if column == "" {
+		return s.options.EmptyAsNil
+	}
 
-		// NOTE: text slices with commands inside are currently not supported.
-		for i, item := range values {
-			v, err := c.parseString(item)
-			if err != nil {
-				return nil, fmt.Errorf("parse slice index %d, string %q: %w", i, item, err)
+	return column == cmp.Or(s.options.NilValue, defaultNilValue)
+}
+
+// fieldByIndexAlloc works like [reflect.Value.FieldByIndex] but allocates any
+// nil struct pointer found along the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, fieldIndex := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
 			}
 
-			slice.Index(i).Set(reflect.ValueOf(v))
+			v = v.Elem()
 		}
 
-		return slice.Interface(), nil
+		v = v.Field(fieldIndex)
+	}
+
+	return v
+}
+
+// isNillable returns whether fields of the given type are marshalled as
+// [Options.NilValue] when nil.
+func isNillable(t reflect.Type) bool {
+	return t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map
+}
+
+// fieldByIndex works like [reflect.Value.FieldByIndex] but returns the zero
+// value if a struct pointer found along the way is nil.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, fieldIndex := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(fieldIndex)
+	}
+
+	return v
+}
+
+// setValue parses the given string and sets it into the target field. A
+// pointer field is allocated if nil, otherwise the value it points to is
+// overwritten.
+func (c *columnDescriptor) setValue(target reflect.Value, str string) error {
+	if c.reflectType.Kind() != reflect.Pointer {
+		return c.decode(str, target)
+	}
+
+	if !target.IsNil() {
+		return c.decode(str, target.Elem())
 	}
 
-	v, err := c.parseString(column)
+	value := reflect.New(c.reflectType.Elem())
+
+	err := c.decode(str, value.Elem())
 	if err != nil {
-		return nil, fmt.Errorf("parse string %q: %w", column, err)
+		return err
 	}
 
-	return v, nil
+	target.Set(value)
+
+	return nil
 }
 
-//nolint:gocyclo,cyclop // Fine for now.
-func (c *columnDescriptor) parseString(str string) (any, error) {
-	// special case
-	//nolint:wrapcheck // Fine for now.
-	if u, ok := c.value.(Unmarshaller); ok {
-		return u.Unmarshal(str)
+// marshalHeaders returns the headers to write. The number of items of the
+// repeated fields, the keys of the expanded maps and those of the extra map,
+// if any, are taken from the given samples and appended in this order, the
+// keys being sorted.
+func (s *structFactory[T]) marshalHeaders(samples ...T) []string {
+	s.itemCounts = s.countItems(samples)
+	s.expandedKeys = s.collectExpandedKeys(samples)
+	s.extraKeys = s.collectExtraKeys(samples)
+
+	headers := make([]string, s.width, s.dynamicWidth())
+
+	for i, column := range s.columns {
+		headers[s.positions[i]] = column.name
 	}
 
-	var (
-		value any
-		err   error
-	)
+	headers = s.repeatedHeaders(headers)
+	headers = s.expandedHeaders(headers)
+	headers = append(headers, s.extraKeys...)
 
-	//nolint:forcetypeassert,gosec // Safe context, we know what we're doing.
-	switch c.value.(type) {
-	case bool:
-		value, err = strconv.ParseBool(str)
-	case int:
-		value, err = strconv.Atoi(str)
-	case int8:
-		value, err = strconv.ParseInt(str, 10, 8)
-		value = int8(value.(int64))
-	case int16:
-		value, err = strconv.ParseInt(str, 10, 16)
-		value = uint16(value.(int64))
-	case int32:
-		value, err = strconv.ParseInt(str, 10, 32)
-		value = int32(value.(int64))
-	case int64:
-		value, err = strconv.ParseInt(str, 10, 64)
-	case uint:
-		value, err = strconv.Atoi(str)
-		value = uint(value.(int))
-	case uint8: // aka byte
-		value, err = strconv.ParseUint(str, 10, 8)
-		value = uint8(value.(uint64))
-	case uint16:
-		value, err = strconv.ParseUint(str, 10, 16)
-		value = uint16(value.(uint64))
-	case uint32:
-		value, err = strconv.ParseUint(str, 10, 32)
-		value = uint32(value.(uint64))
-	case uint64:
-		value, err = strconv.ParseUint(str, 10, 64)
-	case float32:
-		value, err = strconv.ParseFloat(str, 32)
-		value = float32(value.(float64))
-	case float64:
-		value, err = strconv.ParseFloat(str, 64)
-	case string:
-		value = str
-	default:
-		return nil, fmt.Errorf("type %T: %w", c.value, ErrUnsupportedType)
+	return headers[0:len(headers):len(headers)]
+}
+
+// dynamicWidth returns the number of columns of a marshalled row, including
+// those computed by marshalHeaders.
+func (s *structFactory[T]) dynamicWidth() int {
+	return s.width + s.repeatedWidth() + s.expandedWidth() + len(s.extraKeys)
+}
+
+func (s *structFactory[T]) collectExtraKeys(samples []T) []string {
+	if s.extra == nil {
+		return nil
 	}
 
-	if err != nil {
-		return nil, fmt.Errorf("parse string %q: %w", str, err)
+	keys := map[string]struct{}{}
+
+	for _, sample := range samples {
+		extraMap, err := s.structValue(&sample).FieldByIndexErr(s.extra.index)
+		if err != nil {
+			continue
+		}
+
+		for _, key := range extraMap.MapKeys() {
+			keys[key.String()] = struct{}{}
+		}
+	}
+
+	for key := range keys {
+		if s.isBoundHeader(key) {
+			delete(keys, key)
+		}
 	}
 
-	return value, nil
+	return slices.Sorted(maps.Keys(keys))
 }
 
-func (s *structFactory[T]) marshalHeaders() []string {
-	headers := make([]string, 0, len(s.columns))
+// isBoundHeader returns whether the given header would be bound to a column,
+// a repeated field or an expanded map when unmarshalling, rather than be
+// added to the extra map.
+func (s *structFactory[T]) isBoundHeader(header string) bool {
+	normalization := s.options.HeaderNormalization
+	header = normalizeHeader(header, normalization)
 
 	for _, column := range s.columns {
-		if column.name == "" {
-			continue
+		if column.name != "" && slices.Contains(column.normalizedNames(normalization), header) {
+			return true
 		}
+	}
 
-		headers = append(headers, column.name)
+	for _, field := range s.repeated {
+		if _, _, ok := field.match(header, normalization); ok {
+			return true
+		}
 	}
 
-	return headers[0:len(headers):len(headers)]
+	for _, field := range s.expanded {
+		for _, prefix := range field.value.normalizedNames(normalization) {
+			if key, ok := strings.CutPrefix(header, prefix); ok && key != "" {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
-func (s *structFactory[T]) marshal(t T) ([]string, error) {
-	reflectValue := reflect.ValueOf(t)
+// structValue returns the reflected struct behind the given value.
+func (s *structFactory[T]) structValue(t *T) reflect.Value {
+	reflectValue := reflect.ValueOf(t).Elem()
 
 	if s.pointer {
 		reflectValue = reflectValue.Elem()
 	}
 
-	record := make([]string, 0, len(s.columns))
+	return reflectValue
+}
+
+// marshal converts the given value to a record. It takes a pointer so that
+// callers holding the values in a slice can marshal them without copying
+// them to the heap.
+func (s *structFactory[T]) marshal(t *T) ([]string, error) {
+	flat := s.flatMarshaller(t)
+
+	// The generated code, if any, makes reflection unnecessary unless there
+	// is an extra map, a repeated field or an expanded map.
+	var reflectValue reflect.Value
+	if flat == nil || s.extra != nil || len(s.repeated) > 0 || len(s.expanded) > 0 {
+		reflectValue = s.structValue(t)
+	}
+
+	record := make([]string, s.width, s.dynamicWidth())
 
 	var (
 		strValue string
 		err      error
 	)
 
+	nilValue := cmp.Or(s.options.NilValue, defaultNilValue)
+	if s.options.EmptyAsNil {
+		nilValue = ""
+	}
+
 	//nolint:varnamelen // Fine for now.
 	for i, column := range s.columns {
-		if column.name == "" {
-			continue
+		if flat != nil {
+			strValue, err = s.marshalFlatColumn(flat, i, nilValue)
+		} else {
+			strValue, err = s.marshalColumn(reflectValue, i, nilValue)
 		}
 
-		strValue, err = reflectValueToStr(reflectValue.Field(i))
 		if err != nil {
-			return nil, fmt.Errorf("column %d: %w", i, err)
+			return nil, &MarshalError{
+				Field: column.fieldName,
+				Err:   fmt.Errorf("column %d: %w", i, err),
+			}
 		}
 
-		record = append(record, strValue)
+		record[s.positions[i]] = strValue
+	}
+
+	record, err = s.marshalRepeated(reflectValue, record, nilValue)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err = s.marshalExpanded(reflectValue, record, nilValue)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err = s.marshalExtra(reflectValue, record)
+	if err != nil {
+		return nil, err
 	}
 
 	record = record[0:len(record):len(record)]
@@ -305,27 +1052,121 @@ func (s *structFactory[T]) marshal(t T) ([]string, error) {
 	return record, nil
 }
 
-func reflectValueToStr(value reflect.Value) (string, error) {
-	const nilStrValue = "nil"
+// marshalColumn converts the field of the column at the given index to a
+// string.
+func (s *structFactory[T]) marshalColumn(reflectValue reflect.Value, i int, nilValue string) (string, error) {
+	column := s.columns[i]
 
-	// Handle pointer values
-	if value.Kind() == reflect.Pointer {
-		if value.IsNil() {
-			return nilStrValue, nil
+	fieldValue, err := reflectValue.FieldByIndexErr(column.index)
+	if err != nil {
+		// FieldByIndexErr only fails on nil struct pointers.
+		return nilValue, nil //nolint:nilerr // Fine here.
+	}
+
+	return column.marshalField(fieldValue, nilValue)
+}
+
+// marshalField converts the given field of the column to a string, honouring
+// the omitempty tag option.
+func (c *columnDescriptor) marshalField(fieldValue reflect.Value, nilValue string) (string, error) {
+	if c.omitEmpty && fieldValue.IsZero() {
+		return "", nil
+	}
+
+	if isNillable(fieldValue.Type()) && fieldValue.IsNil() {
+		return nilValue, nil
+	}
+
+	return c.marshalValue(fieldValue)
+}
+
+// marshalExtra appends the values of the extra map to the record, in the same
+// order as the headers.
+func (s *structFactory[T]) marshalExtra(reflectValue reflect.Value, record []string) ([]string, error) {
+	if s.extra == nil {
+		return record, nil
+	}
+
+	extraMap, err := reflectValue.FieldByIndexErr(s.extra.index)
+	if err != nil || extraMap.Len() == 0 {
+		return append(record, make([]string, len(s.extraKeys))...), nil
+	}
+
+	found := 0
+
+	for _, key := range s.extraKeys {
+		value := extraMap.MapIndex(reflect.ValueOf(key).Convert(s.extra.reflectType.Key()))
+		if !value.IsValid() {
+			record = append(record, "")
+
+			continue
 		}
 
-		value = value.Elem()
+		found++
+
+		record = append(record, value.String())
 	}
 
-	if m, ok := value.Interface().(Marshaller); ok {
-		// Custom marshaller special case
-		strValue, err := m.Marshal()
-		if err != nil {
-			return "", fmt.Errorf("marshal: %w", err)
+	if found == extraMap.Len() {
+		return record, nil
+	}
+
+	for _, key := range extraMap.MapKeys() {
+		if slices.Contains(s.extraKeys, key.String()) {
+			continue
+		}
+
+		err := ErrUnknownColumn
+		if s.isBoundHeader(key.String()) {
+			err = ErrDuplicatedHeader
+		}
+
+		return nil, &MarshalError{
+			Field: s.extra.fieldName,
+			Err:   fmt.Errorf("key %q: %w", key.String(), err),
 		}
+	}
+
+	return record, nil
+}
 
-		return strValue, nil
+// marshalValue converts the given value to a string, see
+// [columnDescriptor.encoder].
+func (c *columnDescriptor) marshalValue(value reflect.Value) (string, error) {
+	// Handle pointer values, nil ones are handled by the caller.
+	if value.Kind() == reflect.Pointer {
+		value = value.Elem()
 	}
 
-	return fmt.Sprintf("%v", value.Interface()), nil
+	return c.encode(value)
+}
+
+// asInterface returns the given value as the interface I, if either the value
+// or a pointer to it implement it. This allows pointer receiver methods to be
+// used for non-addressable values.
+//
+//nolint:ireturn // Generic by design.
+func asInterface[I any](value reflect.Value) (I, bool) {
+	if i, ok := value.Interface().(I); ok {
+		return i, true
+	}
+
+	var zero I
+
+	if !reflect.PointerTo(value.Type()).Implements(reflect.TypeFor[I]()) {
+		return zero, false
+	}
+
+	var pv reflect.Value
+
+	if value.CanAddr() {
+		pv = value.Addr()
+	} else {
+		pv = reflect.New(value.Type())
+		pv.Elem().Set(value)
+	}
+
+	i, ok := pv.Interface().(I)
+
+	return i, ok
 }
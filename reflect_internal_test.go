@@ -1,6 +1,7 @@
 package goflat
 
 import (
+	"errors"
 	"maps"
 	"reflect"
 	"testing"
@@ -21,6 +22,11 @@ func testReflectError(t *testing.T) {
 	t.Run("tagless", testReflectErrorTaglessStrict)
 	t.Run("missing", testReflectErrorMissing)
 	t.Run("duplicate", testReflectErrorDuplicate)
+	t.Run("recursive", testReflectErrorRecursive)
+	t.Run("invalid default", testReflectErrorInvalidDefault)
+	t.Run("extra", testReflectErrorExtra)
+	t.Run("json", testReflectErrorJSON)
+	t.Run("index", testReflectErrorIndex)
 }
 
 func testReflectErrorTaglessStrict(t *testing.T) {
@@ -78,11 +84,104 @@ func testReflectErrorDuplicate(t *testing.T) {
 	}
 }
 
+func testReflectErrorRecursive(t *testing.T) {
+	type node struct {
+		Name string `flat:"name"`
+		Next *node  `flat:"next"`
+	}
+
+	got, err := newFactory[node]([]string{"name"}, Options{})
+	if got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("expected %v, got %v", ErrUnsupportedType, err)
+	}
+}
+
+func testReflectErrorInvalidDefault(t *testing.T) {
+	type foo struct {
+		Age int `flat:"age,default=old"`
+	}
+
+	got, err := newFactory[foo]([]string{"age"}, Options{})
+	if got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+
+	if !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("expected %v, got %v", ErrInvalidTag, err)
+	}
+}
+
+func testReflectErrorExtra(t *testing.T) {
+	t.Run("type", func(t *testing.T) {
+		type foo struct {
+			Extra map[string]int `flat:",extra"`
+		}
+
+		_, err := newFactory[foo](nil, Options{})
+		if !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("expected %v, got %v", ErrUnsupportedType, err)
+		}
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		type foo struct {
+			Extra1 map[string]string `flat:",extra"`
+			Extra2 map[string]string `flat:",extra"`
+		}
+
+		_, err := newFactory[foo](nil, Options{})
+		if !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("expected %v, got %v", ErrInvalidTag, err)
+		}
+	})
+}
+
+func testReflectErrorJSON(t *testing.T) {
+	type foo struct {
+		Tags []string `flat:"tags,json,sep=;"`
+	}
+
+	_, err := newFactory[foo](nil, Options{})
+	if !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("expected %v, got %v", ErrInvalidTag, err)
+	}
+}
+
+func testReflectErrorIndex(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		type foo struct {
+			Name string `flat:"#first"`
+		}
+
+		_, err := newFactory[foo](nil, Options{})
+		if !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("expected %v, got %v", ErrInvalidTag, err)
+		}
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		type foo struct {
+			Name    string `flat:"#1"`
+			Surname string `flat:"surname,index=1"`
+		}
+
+		_, err := newFactory[foo](nil, Options{})
+		if !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("expected %v, got %v", ErrInvalidTag, err)
+		}
+	})
+}
+
 func testReflectSuccess(t *testing.T) {
 	t.Run("duplicate", testReflectSuccessDuplicate)
 	t.Run("simple", testReflectSuccessSimple)
 	t.Run("subset struct", testReflectSuccessSubsetStruct)
 	t.Run("pointer", testReflectSuccessPointer)
+	t.Run("nested", testReflectSuccessNested)
 }
 
 func testReflectSuccessDuplicate(t *testing.T) {
@@ -110,12 +209,10 @@ func testReflectSuccessDuplicate(t *testing.T) {
 		columns: []*columnDescriptor{
 			{
 				name:        "name",
-				value:       "",
 				reflectType: reflect.TypeFor[string](),
 			},
 			{
 				name:        "age",
-				value:       int(0),
 				reflectType: reflect.TypeFor[int](),
 			},
 		},
@@ -227,7 +324,6 @@ func testReflectSuccessSubsetStruct(t *testing.T) {
 		columns: []*columnDescriptor{
 			{
 				name:        "col2",
-				value:       float32(0),
 				reflectType: reflect.TypeFor[float32](),
 			},
 		},
@@ -312,3 +408,57 @@ func testReflectSuccessPointer(t *testing.T) {
 		t.Errorf("(-want +got):\\n%s", diff)
 	}
 }
+
+func testReflectSuccessNested(t *testing.T) {
+	type address struct {
+		Street string `flat:"street"`
+		City   string `flat:"city"`
+	}
+
+	type Base struct {
+		ID int `flat:"id"`
+	}
+
+	type foo struct {
+		Base
+		Name     string   `flat:"name"`
+		Address  address  `flat:"address"`
+		Previous *address `flat:"previous"`
+		Ignored  address  `flat:"-"`
+	}
+
+	headers := []string{"id", "name", "address.street", "address.city", "previous.city"}
+
+	got, err := newFactory[foo](headers, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	type column struct {
+		Name  string
+		Index []int
+	}
+
+	expectedColumns := []column{
+		{Name: "id", Index: []int{0, 0}},
+		{Name: "name", Index: []int{1}},
+		{Name: "address.street", Index: []int{2, 0}},
+		{Name: "address.city", Index: []int{2, 1}},
+		{Name: "previous.street", Index: []int{3, 0}},
+		{Name: "previous.city", Index: []int{3, 1}},
+	}
+
+	gotColumns := make([]column, 0, len(got.columns))
+	for _, c := range got.columns {
+		gotColumns = append(gotColumns, column{Name: c.name, Index: c.index})
+	}
+
+	if diff := cmp.Diff(expectedColumns, gotColumns); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	expectedMap := map[int]int{0: 0, 1: 1, 2: 2, 3: 3, 4: 5}
+	if diff := cmp.Diff(expectedMap, got.columnMap); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
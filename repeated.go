@@ -0,0 +1,377 @@
+package goflat
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// repeatedPlaceholder is replaced by the number of the item, starting from 1,
+// in the names of the columns of repeated fields, e.g. `flat:"phone_{n}"`.
+const repeatedPlaceholder = "{n}"
+
+// repeatedField is a slice field whose items are spread across numbered
+// columns rather than joined in a single cell.
+type repeatedField struct {
+	// fieldName is the name of the slice, as in [columnDescriptor].
+	fieldName   string
+	reflectType reflect.Type
+	// index is the path to the slice starting from the root struct.
+	index []int
+	// columns are those of a single item, their names contain the
+	// placeholder. Items which are structs have a column for each of their
+	// fields, indexed from the item, otherwise there is a single column with
+	// an empty index.
+	columns []*columnDescriptor
+	// max is the number of items written when marshalling and the highest
+	// item read when unmarshalling, 0 if unbounded.
+	max int
+	// pointerStructs are the paths to the struct pointers within an item,
+	// starting from the item: an empty path is the item itself.
+	pointerStructs [][]int
+}
+
+// repeatedBinding maps a header to the column of an item of a repeated field.
+type repeatedBinding struct {
+	field  int
+	item   int
+	column int
+}
+
+// addRepeated adds a repeated field, see [repeatedField].
+//
+//nolint:cyclop // Fine-ish here.
+func (s *structInfo) addRepeated(
+	fieldT reflect.StructField, fieldIndex []int, path fieldPath, name string, tagOpts tagOptions,
+	visiting map[reflect.Type]bool,
+) error {
+	t := fieldT.Type
+	if t.Kind() != reflect.Slice {
+		return fmt.Errorf("repeated field of type %s is not a slice: %w", t, ErrUnsupportedType)
+	}
+
+	for _, alias := range strings.Split(name, aliasSeparator) {
+		if strings.Count(alias, repeatedPlaceholder) != 1 {
+			return fmt.Errorf("name %q must contain %s once: %w", alias, repeatedPlaceholder, ErrInvalidTag)
+		}
+	}
+
+	for _, prefix := range path.prefixes {
+		if strings.Contains(prefix, repeatedPlaceholder) {
+			return fmt.Errorf("repeated field within repeated field: %w", ErrUnsupportedType)
+		}
+	}
+
+	for _, option := range []string{"index", "pos", "width", "align", "pad", "required", "default"} {
+		if tagOpts.has(option) {
+			return fmt.Errorf("option %q on repeated field: %w", option, ErrInvalidTag)
+		}
+	}
+
+	field := &repeatedField{
+		fieldName:   path.fieldPrefix + fieldT.Name,
+		reflectType: t,
+		index:       fieldIndex,
+	}
+
+	if maxStr, ok := tagOpts["max"]; ok {
+		maxItems, err := strconv.Atoi(maxStr)
+		if err != nil || maxItems < 1 {
+			return fmt.Errorf("max %q: %w", maxStr, ErrInvalidTag)
+		}
+
+		field.max = maxItems
+	}
+
+	itemT := t.Elem()
+
+	if !isNestedStruct(itemT) {
+		column, err := s.newColumn(joinNames(path.prefixes, name, ""), itemT, tagOpts)
+		if err != nil {
+			return err
+		}
+
+		field.columns = []*columnDescriptor{column}
+		s.repeated = append(s.repeated, field)
+
+		return nil
+	}
+
+	items := &structInfo{key: s.key}
+
+	if itemT.Kind() == reflect.Pointer {
+		itemT = itemT.Elem()
+		items.pointerStructs = [][]int{{}}
+	}
+
+	err := items.collectColumns(itemT, fieldPath{
+		prefixes:    joinNames(path.prefixes, name, nestedSeparator),
+		fieldPrefix: nestedSeparator,
+	}, visiting)
+	if err != nil {
+		return err
+	}
+
+	if items.extra != nil {
+		return fmt.Errorf("extra field %q within repeated field: %w", items.extra.fieldName, ErrUnsupportedType)
+	}
+
+	for _, column := range items.columns {
+		if column.positional {
+			return fmt.Errorf("positional field %q within repeated field: %w", column.fieldName, ErrInvalidTag)
+		}
+	}
+
+	field.columns = items.columns
+	field.pointerStructs = items.pointerStructs
+	s.repeated = append(s.repeated, field)
+
+	return nil
+}
+
+// match returns the item and the column matching the given normalized
+// header, if any.
+func (r *repeatedField) match(header string, normalization HeaderNormalization) (int, int, bool) {
+	for i, column := range r.columns {
+		for _, name := range column.normalizedNames(normalization) {
+			before, after, _ := strings.Cut(name, repeatedPlaceholder)
+
+			number, ok := strings.CutPrefix(header, before)
+			if !ok {
+				continue
+			}
+
+			number, ok = strings.CutSuffix(number, after)
+			if !ok || number == "" || strings.Trim(number, "0123456789") != "" {
+				continue
+			}
+
+			n, err := strconv.Atoi(number)
+			if err != nil || n < 1 || (r.max > 0 && n > r.max) {
+				continue
+			}
+
+			return n - 1, i, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// itemName returns the name of the Go field behind the given column of an
+// item, e.g. "Items[0].SKU".
+func (r *repeatedField) itemName(item, column int) string {
+	return fmt.Sprintf("%s[%d]%s", r.fieldName, item, r.columns[column].fieldName)
+}
+
+// bindRepeated maps the headers which are not bound to any column to the
+// items of the repeated fields.
+func (s *structFactory[T]) bindRepeated(normalizedHeaders []string, covered []bool) error {
+	s.repeatedMap = make(map[int]repeatedBinding)
+	handledAt := make(map[repeatedBinding]int)
+
+	for j, header := range normalizedHeaders {
+		if covered[j] {
+			continue
+		}
+
+		for i, field := range s.repeated {
+			item, column, ok := field.match(header, s.options.HeaderNormalization)
+			if !ok {
+				continue
+			}
+
+			binding := repeatedBinding{field: i, item: item, column: column}
+
+			if other, ok := handledAt[binding]; ok {
+				if s.options.ErrorIfDuplicateHeaders {
+					return fmt.Errorf("header %q, index %d and %d: %w", s.headers[j], j, other, ErrDuplicatedHeader)
+				}
+
+				covered[j] = true
+
+				break
+			}
+
+			handledAt[binding] = j
+			covered[j] = true
+			s.repeatedMap[j] = binding
+
+			break
+		}
+	}
+
+	return nil
+}
+
+// clearRepeated truncates the repeated fields, so that they only hold the
+// items of the row being unmarshalled. The backing arrays are reused.
+func (s *structFactory[T]) clearRepeated(newStruct reflect.Value) {
+	for _, field := range s.repeated {
+		slice := fieldByIndex(newStruct, field.index)
+		if slice.IsValid() && !slice.IsNil() {
+			slice.SetLen(0)
+		}
+	}
+}
+
+// setRepeated parses the given column and sets it into its item, growing the
+// slice as needed. Empty columns are skipped, so that the slice ends with the
+// last item which has a value, as well as those of nil struct pointers, see
+// [nilGroup]. Since missing items are marshalled as empty columns too,
+// trailing empty items are lost in a round trip.
+func (s *structFactory[T]) setRepeated(newStruct reflect.Value, binding repeatedBinding, column string, nilStruct bool) error {
+	if column == "" {
+		return nil
+	}
+
+	field := s.repeated[binding.field]
+	columnDescriptor := field.columns[binding.column]
+
+	slice := fieldByIndexAlloc(newStruct, field.index)
+	for slice.Len() <= binding.item {
+		slice.Set(reflect.Append(slice, reflect.Zero(field.reflectType.Elem())))
+	}
+
+	if nilStruct || (columnDescriptor.nullable && s.isNil(column)) {
+		return nil
+	}
+
+	item := slice.Index(binding.item)
+
+	if columnDescriptor.index != nil && item.Kind() == reflect.Pointer {
+		if item.IsNil() {
+			item.Set(reflect.New(item.Type().Elem()))
+		}
+
+		item = item.Elem()
+	}
+
+	err := columnDescriptor.setValue(fieldByIndexAlloc(item, columnDescriptor.index), column)
+	if err != nil {
+		return fmt.Errorf("parse string %q: %w", column, err)
+	}
+
+	return nil
+}
+
+// countItems returns the number of items written for each repeated field:
+// either their max tag option or the length of the longest slice among the
+// given samples.
+func (s *structFactory[T]) countItems(samples []T) []int {
+	if len(s.repeated) == 0 {
+		return nil
+	}
+
+	counts := make([]int, len(s.repeated))
+
+	for i, field := range s.repeated {
+		if field.max > 0 {
+			counts[i] = field.max
+
+			continue
+		}
+
+		for _, sample := range samples {
+			slice, err := s.structValue(&sample).FieldByIndexErr(field.index)
+			if err == nil {
+				counts[i] = max(counts[i], slice.Len())
+			}
+		}
+	}
+
+	return counts
+}
+
+// repeatedHeaders appends the headers of the repeated fields, numbering the
+// columns of each item.
+func (s *structFactory[T]) repeatedHeaders(headers []string) []string {
+	for i, field := range s.repeated {
+		for n := 1; n <= s.itemCounts[i]; n++ {
+			for _, column := range field.columns {
+				headers = append(headers, strings.Replace(column.name, repeatedPlaceholder, strconv.Itoa(n), 1))
+			}
+		}
+	}
+
+	return headers
+}
+
+// marshalRepeated appends the items of the repeated fields to the record, in
+// the same order as the headers. Missing items are written as empty columns.
+func (s *structFactory[T]) marshalRepeated(reflectValue reflect.Value, record []string, nilValue string) ([]string, error) {
+	for i, field := range s.repeated {
+		count := s.itemCounts[i]
+
+		slice, err := reflectValue.FieldByIndexErr(field.index)
+		if err != nil {
+			slice = reflect.Zero(field.reflectType)
+		}
+
+		if slice.Len() > count {
+			return nil, &MarshalError{
+				Field: field.fieldName,
+				Err:   fmt.Errorf("%d items, %d columns: %w", slice.Len(), count, ErrTooManyItems),
+			}
+		}
+
+		for item := range count {
+			if item >= slice.Len() {
+				record = append(record, make([]string, len(field.columns))...)
+
+				continue
+			}
+
+			for j, column := range field.columns {
+				value, err := marshalItem(slice.Index(item), column, nilValue)
+				if err != nil {
+					return nil, &MarshalError{
+						Field: field.itemName(item, j),
+						Err:   err,
+					}
+				}
+
+				record = append(record, value)
+			}
+		}
+	}
+
+	return record, nil
+}
+
+// marshalItem converts the column of the given item to a string.
+func marshalItem(item reflect.Value, column *columnDescriptor, nilValue string) (string, error) {
+	if column.index == nil {
+		return column.marshalField(item, nilValue)
+	}
+
+	if item.Kind() == reflect.Pointer {
+		if item.IsNil() {
+			return nilValue, nil
+		}
+
+		item = item.Elem()
+	}
+
+	fieldValue, err := item.FieldByIndexErr(column.index)
+	if err != nil {
+		// A struct pointer within the item is nil.
+		return nilValue, nil //nolint:nilerr // Fine here.
+	}
+
+	return column.marshalField(fieldValue, nilValue)
+}
+
+// repeatedWidth returns the number of columns of the repeated fields.
+func (s *structFactory[T]) repeatedWidth() int {
+	width := 0
+
+	for i, field := range s.repeated {
+		if i < len(s.itemCounts) {
+			width += s.itemCounts[i] * len(field.columns)
+		}
+	}
+
+	return width
+}
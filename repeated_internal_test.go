@@ -0,0 +1,142 @@
+package goflat
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRepeated(t *testing.T) {
+	t.Run("error", testRepeatedError)
+	t.Run("match", testRepeatedMatch)
+}
+
+func testRepeatedError(t *testing.T) {
+	type item struct {
+		Name  string            `flat:"name"`
+		Extra map[string]string `flat:",extra"`
+	}
+
+	type nested struct {
+		Phones []string `flat:"phone_{n}"`
+	}
+
+	tcs := map[string]struct {
+		newFactory func() error
+		expected   error
+	}{
+		"not a slice": {
+			newFactory: func() error {
+				_, err := newFactory[struct {
+					Phone string `flat:"phone_{n}"`
+				}](nil, Options{})
+
+				return err
+			},
+			expected: ErrUnsupportedType,
+		},
+		"alias without placeholder": {
+			newFactory: func() error {
+				_, err := newFactory[struct {
+					Phones []string `flat:"phone_{n}|phone"`
+				}](nil, Options{})
+
+				return err
+			},
+			expected: ErrInvalidTag,
+		},
+		"invalid max": {
+			newFactory: func() error {
+				_, err := newFactory[struct {
+					Phones []string `flat:"phone_{n},max=0"`
+				}](nil, Options{})
+
+				return err
+			},
+			expected: ErrInvalidTag,
+		},
+		"positional": {
+			newFactory: func() error {
+				_, err := newFactory[struct {
+					Phones []string `flat:"phone_{n},index=2"`
+				}](nil, Options{})
+
+				return err
+			},
+			expected: ErrInvalidTag,
+		},
+		"extra item": {
+			newFactory: func() error {
+				_, err := newFactory[struct {
+					Items []item `flat:"item_{n}"`
+				}](nil, Options{})
+
+				return err
+			},
+			expected: ErrUnsupportedType,
+		},
+		"nested repeated": {
+			newFactory: func() error {
+				_, err := newFactory[struct {
+					Contacts []nested `flat:"contact_{n}"`
+				}](nil, Options{})
+
+				return err
+			},
+			expected: ErrUnsupportedType,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			err := tc.newFactory()
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func testRepeatedMatch(t *testing.T) {
+	type item struct {
+		SKU string `flat:"sku"`
+		Qty int    `flat:"qty"`
+	}
+
+	factory, err := newFactory[struct {
+		Items []item `flat:"item_{n}|line_{n},max=10"`
+	}](nil, Options{})
+	if err != nil {
+		t.Fatalf("new factory: %v", err)
+	}
+
+	type match struct {
+		Item, Column int
+		OK           bool
+	}
+
+	tcs := map[string]match{
+		"item_1.sku":  {Item: 0, Column: 0, OK: true},
+		"item_10.qty": {Item: 9, Column: 1, OK: true},
+		"line_3.qty":  {Item: 2, Column: 1, OK: true},
+		"item_11.sku": {},
+		"item_0.sku":  {},
+		"item_.sku":   {},
+		"item_+1.sku": {},
+		"item_1.name": {},
+		"item_1.sku2": {},
+	}
+
+	for header, expected := range tcs {
+		t.Run(header, func(t *testing.T) {
+			var got match
+
+			got.Item, got.Column, got.OK = factory.repeated[0].match(header, 0)
+
+			if diff := cmp.Diff(expected, got); diff != "" {
+				t.Errorf("(-expected,+got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package goflat_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"log/slog"
+	"math"
+	"math/big"
+	"math/rand/v2"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/lzambarda/goflat"
+)
+
+type roundTripNested struct {
+	Name  string `flat:"name"`
+	Count int    `flat:"count"`
+}
+
+type roundTripRecord struct {
+	Bool      bool              `flat:"bool"`
+	Int       int               `flat:"int"`
+	Int8      int8              `flat:"int8"`
+	Int16     int16             `flat:"int16"`
+	Int32     int32             `flat:"int32"`
+	Int64     int64             `flat:"int64"`
+	Uint      uint              `flat:"uint"`
+	Uint8     uint8             `flat:"uint8"`
+	Uint16    uint16            `flat:"uint16"`
+	Uint32    uint32            `flat:"uint32"`
+	Uint64    uint64            `flat:"uint64"`
+	Float32   float32           `flat:"float32"`
+	Float64   float64           `flat:"float64"`
+	String    string            `flat:"string"`
+	Time      time.Time         `flat:"time"`
+	Date      time.Time         `flat:"date,layout=2006-01-02"`
+	Epoch     time.Time         `flat:"epoch,layout=unixmilli"`
+	Duration  time.Duration     `flat:"duration"`
+	Addr      netip.Addr        `flat:"addr"`
+	Level     slog.Level        `flat:"level"`
+	Big       *big.Int          `flat:"big"`
+	PtrBool   *bool             `flat:"ptr_bool"`
+	PtrInt    *int              `flat:"ptr_int"`
+	PtrFloat  *float64          `flat:"ptr_float"`
+	PtrString *string           `flat:"ptr_string"`
+	PtrTime   *time.Time        `flat:"ptr_time"`
+	Nested    roundTripNested   `flat:"nested"`
+	PtrNested *roundTripNested  `flat:"ptr_nested"`
+	Strings   []string          `flat:"strings"`
+	Ints      []int             `flat:"ints,sep=;,brackets=[]"`
+	Words     []string          `flat:"words,sep=' | ',brackets=()"`
+	Attrs     map[string]int    `flat:"attrs"`
+	Labels    map[string]string `flat:"labels,sep=' | ',kvsep=:"`
+	Payload   roundTripNested   `flat:"payload,json"`
+	Amount    float64           `flat:"amount,decimal=',',thousands=.,currency=€,accounting"`
+	Ratio     float32           `flat:"ratio,percent"`
+	Grouped   int               `flat:"grouped,thousands=' '"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	tcs := map[string]struct {
+		options     goflat.Options
+		emptyIsNil  bool
+		recordCount int
+	}{
+		"default": {
+			options:     goflat.Options{},
+			recordCount: 200,
+		},
+		"custom nil value": {
+			options:     goflat.Options{NilValue: "NULL"},
+			recordCount: 200,
+		},
+		"empty as nil": {
+			options:     goflat.Options{EmptyAsNil: true},
+			emptyIsNil:  true,
+			recordCount: 200,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(1, 2)) //nolint:gosec // Fine for tests.
+
+			input := make([]roundTripRecord, tc.recordCount)
+			for i := range input {
+				input[i] = randomRecord(rng, tc.emptyIsNil)
+			}
+
+			var buffer bytes.Buffer
+
+			err := goflat.MarshalSliceToWriter(t.Context(), input, csv.NewWriter(&buffer), tc.options)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+
+			got, err := goflat.UnmarshalToSlice[roundTripRecord](t.Context(), csv.NewReader(&buffer), tc.options)
+			if err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+
+			expected := make([]roundTripRecord, len(input))
+			for i, record := range input {
+				// Pointers to the nil value cannot be told apart from nil ones.
+				if record.PtrString != nil && isNilValue(*record.PtrString, tc.options) {
+					record.PtrString = nil
+				}
+
+				expected[i] = record
+			}
+
+			comparers := []cmp.Option{
+				cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) }),
+				cmp.Comparer(func(a, b netip.Addr) bool { return a == b }),
+				cmp.Comparer(func(a, b *big.Int) bool {
+					if a == nil || b == nil {
+						return a == b
+					}
+
+					return a.Cmp(b) == 0
+				}),
+			}
+
+			if diff := cmp.Diff(expected, got, comparers...); diff != "" {
+				t.Errorf("(-expected,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+//nolint:gosec // Truncation is intended.
+func randomRecord(rng *rand.Rand, emptyIsNil bool) roundTripRecord {
+	date := time.Date(1900+rng.IntN(300), time.Month(1+rng.IntN(12)), 1+rng.IntN(28), 0, 0, 0, 0, time.UTC)
+	moment := date.Add(time.Duration(rng.Int64N(int64(24 * time.Hour))))
+
+	record := roundTripRecord{
+		Bool:     rng.IntN(2) == 0,
+		Int:      int(rng.Uint64()),
+		Int8:     int8(rng.Uint64()),
+		Int16:    int16(rng.Uint64()),
+		Int32:    int32(rng.Uint64()),
+		Int64:    int64(rng.Uint64()),
+		Uint:     uint(rng.Uint64()),
+		Uint8:    uint8(rng.Uint64()),
+		Uint16:   uint16(rng.Uint64()),
+		Uint32:   uint32(rng.Uint64()),
+		Uint64:   rng.Uint64(),
+		Float32:  math.Float32frombits(rng.Uint32()),
+		Float64:  rng.NormFloat64() * math.Pow(10, float64(rng.IntN(40)-20)),
+		String:   randomText(rng),
+		Time:     moment.In(time.FixedZone("", 3600*(rng.IntN(24)-12))),
+		Date:     date,
+		Epoch:    time.UnixMilli(moment.UnixMilli()),
+		Duration: time.Duration(rng.Int64()),
+		Addr:     netip.AddrFrom4([4]byte{byte(rng.Uint32()), byte(rng.Uint32()), byte(rng.Uint32()), byte(rng.Uint32())}),
+		Level:    slog.Level(rng.IntN(16) - 8),
+		Nested: roundTripNested{
+			Name:  randomText(rng),
+			Count: rng.Int(),
+		},
+	}
+
+	if math.IsNaN(float64(record.Float32)) {
+		record.Float32 = 0
+	}
+
+	if rng.IntN(2) == 0 {
+		record.Big = new(big.Int).Mul(big.NewInt(rng.Int64()), big.NewInt(rng.Int64()))
+		record.PtrBool = ptrTo(rng.IntN(2) == 0)
+		record.PtrInt = ptrTo(rng.Int())
+		record.PtrFloat = ptrTo(rng.Float64())
+		record.PtrString = ptrTo(randomText(rng))
+		record.PtrTime = ptrTo(moment)
+		record.PtrNested = &roundTripNested{
+			Name:  randomText(rng),
+			Count: rng.Int(),
+		}
+	}
+
+	record.Strings = randomSlice(rng, emptyIsNil, func() string { return randomText(rng) })
+	record.Ints = randomSlice(rng, emptyIsNil, rng.Int)
+	record.Words = randomSlice(rng, emptyIsNil, func() string { return randomString(rng) })
+	record.Attrs = randomMap(rng, emptyIsNil, rng.Int)
+	record.Labels = randomMap(rng, emptyIsNil, func() string { return randomString(rng) })
+	record.Payload = roundTripNested{Name: randomString(rng), Count: rng.Int()}
+	record.Amount = rng.NormFloat64() * 1e6
+	record.Ratio = rng.Float32()
+	record.Grouped = rng.Int() - rng.Int()
+
+	return record
+}
+
+// isNilValue returns whether the given cell is unmarshalled as nil.
+func isNilValue(cell string, options goflat.Options) bool {
+	if cell == "" {
+		return options.EmptyAsNil
+	}
+
+	if options.NilValue != "" {
+		return cell == options.NilValue
+	}
+
+	return cell == "nil"
+}
+
+// randomMap works like [randomSlice] for maps with random keys.
+func randomMap[T any](rng *rand.Rand, emptyIsNil bool, value func() T) map[string]T {
+	switch rng.IntN(3) {
+	case 0:
+		return nil
+	case 1:
+		if !emptyIsNil {
+			return map[string]T{}
+		}
+	}
+
+	m := make(map[string]T)
+	for range 1 + rng.IntN(4) {
+		m[randomString(rng)] = value()
+	}
+
+	return m
+}
+
+// randomSlice returns either a nil, an empty or a filled slice. Empty slices
+// are only returned if they are not unmarshalled as nil.
+func randomSlice[T any](rng *rand.Rand, emptyIsNil bool, item func() T) []T {
+	switch rng.IntN(3) {
+	case 0:
+		return nil
+	case 1:
+		if !emptyIsNil {
+			return []T{}
+		}
+	}
+
+	slice := make([]T, 1+rng.IntN(4))
+	for i := range slice {
+		slice[i] = item()
+	}
+
+	return slice
+}
+
+// roundTripNilValues are the nil values used in the tests.
+var roundTripNilValues = []string{"nil", "NULL"}
+
+// randomText works like [randomString], but it may also return one of the
+// nil values used in the tests: fields which are not pointers, and the items
+// of slices, must keep it, while pointers to it are read back as nil.
+func randomText(rng *rand.Rand) string {
+	if rng.IntN(4) == 0 {
+		return roundTripNilValues[rng.IntN(len(roundTripNilValues))]
+	}
+
+	return randomString(rng)
+}
+
+// randomString returns a random string made of characters which are likely to
+// need escaping, but never one of the nil values used in the tests.
+func randomString(rng *rand.Rand) string {
+	const alphabet = `abcXYZ019 ,;|"'` + "\t\n" + `é€`
+
+	runes := []rune(alphabet)
+
+	var builder strings.Builder
+
+	for range rng.IntN(10) {
+		builder.WriteRune(runes[rng.IntN(len(runes))])
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,211 @@
+package goflat
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Defaults of the sep and brackets tag options.
+const (
+	defaultSliceSep = ","
+	// legacyBrackets are trimmed when unmarshalling slices without the
+	// brackets tag option, as older versions accepted them.
+	legacyBrackets = "[]{}"
+)
+
+// sliceQuote wraps the items which would otherwise be ambiguous.
+const sliceQuote = '"'
+
+// sliceFormat is the format of the cells of slice fields: the items are
+// joined by sep and optionally wrapped in brackets.
+type sliceFormat struct {
+	sep         string
+	open, close string
+	// nilValue is the cell of nil slices. Without brackets, the items are
+	// quoted so that the cell never equals it.
+	nilValue string
+}
+
+// parseSliceFormat parses the sep and brackets tag options, which are only
+// valid for slices. The sep option of maps is parsed by [parseMapFormat].
+func parseSliceFormat(tagOpts tagOptions, t reflect.Type) (sliceFormat, error) {
+	format := sliceFormat{sep: defaultSliceSep}
+
+	if !tagOpts.has("sep") && !tagOpts.has("brackets") {
+		return format, nil
+	}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.Map {
+		return format, nil
+	}
+
+	if t.Kind() != reflect.Slice {
+		return format, fmt.Errorf("sep and brackets options on type %s: %w", t, ErrInvalidTag)
+	}
+
+	if sep, ok := tagOpts["sep"]; ok {
+		if sep == "" || strings.ContainsRune(sep, sliceQuote) {
+			return format, fmt.Errorf("sep %q: %w", sep, ErrInvalidTag)
+		}
+
+		format.sep = sep
+	}
+
+	if brackets, ok := tagOpts["brackets"]; ok {
+		open, size := utf8.DecodeRuneInString(brackets)
+		if utf8.RuneCountInString(brackets) != 2 || strings.ContainsRune(brackets, sliceQuote) ||
+			strings.ContainsAny(format.sep, brackets) {
+			return format, fmt.Errorf("brackets %q: %w", brackets, ErrInvalidTag)
+		}
+
+		format.open, format.close = string(open), brackets[size:]
+	}
+
+	return format, nil
+}
+
+// trim removes the brackets around the items, if any.
+func (f sliceFormat) trim(str string) string {
+	if f.open != "" {
+		str, _ = strings.CutPrefix(str, f.open)
+		str, _ = strings.CutSuffix(str, f.close)
+
+		return str
+	}
+
+	for i := 0; i < len(legacyBrackets); i += 2 {
+		if len(str) >= 2 && str[0] == legacyBrackets[i] && str[len(str)-1] == legacyBrackets[i+1] {
+			return str[1 : len(str)-1]
+		}
+	}
+
+	return str
+}
+
+// split returns the items of a cell, unquoting them. An empty cell has no
+// items.
+func (f sliceFormat) split(str string) ([]string, error) {
+	str = f.trim(str)
+	if str == "" {
+		return nil, nil
+	}
+
+	var items []string
+
+	for {
+		item, rest, err := f.next(str)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+
+		var found bool
+
+		str, found = strings.CutPrefix(rest, f.sep)
+		if !found {
+			if rest != "" {
+				return nil, fmt.Errorf("text after quoted item %q: %w", item, strconv.ErrSyntax)
+			}
+
+			return items, nil
+		}
+	}
+}
+
+// next returns the first item of the string, unquoting it, and what follows
+// it.
+func (f sliceFormat) next(str string) (string, string, error) {
+	if str == "" || str[0] != sliceQuote {
+		item, _, _ := strings.Cut(str, f.sep)
+
+		return item, str[len(item):], nil
+	}
+
+	var sb strings.Builder
+
+	str = str[1:]
+
+	for {
+		i := strings.IndexByte(str, sliceQuote)
+		if i < 0 {
+			return "", "", fmt.Errorf("unterminated quote: %w", strconv.ErrSyntax)
+		}
+
+		sb.WriteString(str[:i])
+		str = str[i+1:]
+
+		// Quotes inside quoted items are doubled.
+		if str == "" || str[0] != sliceQuote {
+			return sb.String(), str, nil
+		}
+
+		sb.WriteByte(sliceQuote)
+
+		str = str[1:]
+	}
+}
+
+// join returns the cell holding the given items, quoting those which are
+// empty, equal to the nil value or contain the separator, a quote or a
+// bracket. If the cell would still equal the nil value, for instance because
+// it contains the separator, all the items are quoted.
+func (f sliceFormat) join(items []string) string {
+	cell := f.joinItems(items, false)
+	if len(items) > 0 && cell == f.nilValue {
+		return f.joinItems(items, true)
+	}
+
+	return cell
+}
+
+func (f sliceFormat) joinItems(items []string, quoteAll bool) string {
+	brackets := f.open + f.close
+	if brackets == "" {
+		brackets = legacyBrackets
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString(f.open)
+
+	for i, item := range items {
+		if i > 0 {
+			sb.WriteString(f.sep)
+		}
+
+		if !quoteAll && item != "" && item != f.nilValue && !needsQuote(item, f.sep) &&
+			!strings.ContainsAny(item, brackets) {
+			sb.WriteString(item)
+
+			continue
+		}
+
+		sb.WriteString(quote(item))
+	}
+
+	sb.WriteString(f.close)
+
+	return sb.String()
+}
+
+// needsQuote returns whether the given item must be quoted to be followed by
+// the separator: either it contains a quote or the separator would be found
+// before its end, which also happens if the item ends with the beginning of
+// the separator.
+func needsQuote(item, sep string) bool {
+	return strings.ContainsRune(item, sliceQuote) || strings.Index(item+sep, sep) < len(item)
+}
+
+// quote wraps the given item in quotes, doubling the quotes inside it.
+func quote(item string) string {
+	return string(sliceQuote) + strings.ReplaceAll(item, string(sliceQuote), string(sliceQuote)+string(sliceQuote)) +
+		string(sliceQuote)
+}
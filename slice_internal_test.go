@@ -0,0 +1,142 @@
+package goflat
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseSliceFormat(t *testing.T) {
+	t.Run("error", testParseSliceFormatError)
+	t.Run("success", testParseSliceFormatSuccess)
+}
+
+func testParseSliceFormatError(t *testing.T) {
+	tcs := map[string]struct {
+		tagOpts tagOptions
+		t       reflect.Type
+	}{
+		"not a slice":    {tagOpts: tagOptions{"sep": ";"}, t: reflect.TypeFor[string]()},
+		"empty sep":      {tagOpts: tagOptions{"sep": ""}, t: reflect.TypeFor[[]string]()},
+		"quote sep":      {tagOpts: tagOptions{"sep": `"`}, t: reflect.TypeFor[[]string]()},
+		"one bracket":    {tagOpts: tagOptions{"brackets": "["}, t: reflect.TypeFor[[]string]()},
+		"sep in bracket": {tagOpts: tagOptions{"sep": "|", "brackets": "||"}, t: reflect.TypeFor[[]string]()},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseSliceFormat(tc.tagOpts, tc.t)
+			if !errors.Is(err, ErrInvalidTag) {
+				t.Errorf("expected %v, got %v", ErrInvalidTag, err)
+			}
+		})
+	}
+}
+
+func testParseSliceFormatSuccess(t *testing.T) {
+	tcs := map[string]struct {
+		tagOpts  tagOptions
+		t        reflect.Type
+		expected sliceFormat
+	}{
+		"default": {
+			tagOpts:  tagOptions{},
+			t:        reflect.TypeFor[int](),
+			expected: sliceFormat{sep: ","},
+		},
+		"pointer": {
+			tagOpts:  tagOptions{"sep": "; ", "brackets": "«»"},
+			t:        reflect.TypeFor[*[]string](),
+			expected: sliceFormat{sep: "; ", open: "«", close: "»"},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseSliceFormat(tc.tagOpts, tc.t)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expected, got, cmp.AllowUnexported(sliceFormat{})); diff != "" {
+				t.Errorf("(-expected,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSliceFormat(t *testing.T) {
+	t.Run("join", testSliceFormatJoin)
+	t.Run("split", testSliceFormatSplit)
+	t.Run("split error", testSliceFormatSplitError)
+}
+
+func testSliceFormatJoin(t *testing.T) {
+	tcs := map[string]struct {
+		format   sliceFormat
+		items    []string
+		expected string
+	}{
+		"empty":      {format: sliceFormat{sep: ","}, items: []string{}, expected: ""},
+		"brackets":   {format: sliceFormat{sep: ",", open: "[", close: "]"}, items: []string{}, expected: "[]"},
+		"plain":      {format: sliceFormat{sep: ","}, items: []string{"a", "b"}, expected: "a,b"},
+		"empty item": {format: sliceFormat{sep: ","}, items: []string{""}, expected: `""`},
+		"separator":  {format: sliceFormat{sep: ";"}, items: []string{"a;b", "c,d"}, expected: `"a;b";c,d`},
+		"quote":      {format: sliceFormat{sep: ","}, items: []string{`say "hi"`}, expected: `"say ""hi"""`},
+		"legacy":     {format: sliceFormat{sep: ","}, items: []string{"[a", "b]"}, expected: `"[a","b]"`},
+		"sep prefix": {format: sliceFormat{sep: " | "}, items: []string{"a |", "b"}, expected: `"a |" | b`},
+		"nil item":   {format: sliceFormat{sep: ",", nilValue: "nil"}, items: []string{"nil", "a"}, expected: `"nil",a`},
+		"nil cell":   {format: sliceFormat{sep: ",", nilValue: "a,b"}, items: []string{"a", "b"}, expected: `"a","b"`},
+		"nil empty":  {format: sliceFormat{sep: ",", nilValue: ""}, items: []string{}, expected: ""},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got := tc.format.join(tc.items)
+			if got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func testSliceFormatSplit(t *testing.T) {
+	tcs := map[string]struct {
+		format   sliceFormat
+		str      string
+		expected []string
+	}{
+		"empty":          {format: sliceFormat{sep: ","}, str: "", expected: nil},
+		"brackets":       {format: sliceFormat{sep: ",", open: "(", close: ")"}, str: "(a,b)", expected: []string{"a", "b"}},
+		"legacy":         {format: sliceFormat{sep: ","}, str: "{1,2}", expected: []string{"1", "2"}},
+		"trailing":       {format: sliceFormat{sep: ","}, str: "a,", expected: []string{"a", ""}},
+		"quoted":         {format: sliceFormat{sep: ";"}, str: `"a;b";c,d`, expected: []string{"a;b", "c,d"}},
+		"escaped quote":  {format: sliceFormat{sep: ","}, str: `"say ""hi""",x`, expected: []string{`say "hi"`, "x"}},
+		"long separator": {format: sliceFormat{sep: " | "}, str: `a | "b | c"`, expected: []string{"a", "b | c"}},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.format.split(tc.str)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expected, got); diff != "" {
+				t.Errorf("(-expected,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func testSliceFormatSplitError(t *testing.T) {
+	for _, str := range []string{`"a`, `"a"b`} {
+		_, err := sliceFormat{sep: ","}.split(str)
+		if !errors.Is(err, strconv.ErrSyntax) {
+			t.Errorf("%q: expected %v, got %v", str, strconv.ErrSyntax, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package goflat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tagOptions holds the options which can follow the column name in a `flat`
+// tag, e.g. `flat:"created_at,layout=2006-01-02"`. Options without a value
+// are stored with an empty string.
+type tagOptions map[string]string
+
+//nolint:gochecknoglobals // Used for validation.
+var knownTagOptions = map[string]bool{
+	"layout":     true,
+	"required":   true,
+	"default":    true,
+	"omitempty":  true,
+	"extra":      true,
+	"index":      true,
+	"pos":        true,
+	"width":      true,
+	"align":      true,
+	"pad":        true,
+	"sep":        true,
+	"brackets":   true,
+	"max":        true,
+	"kvsep":      true,
+	"expand":     true,
+	"json":       true,
+	"decimal":    true,
+	"thousands":  true,
+	"percent":    true,
+	"currency":   true,
+	"accounting": true,
+	"precision":  true,
+}
+
+// parseTag splits a `flat` tag into the column name and its options. Option
+// values can be wrapped in single quotes to include commas, e.g.
+// `layout='Jan 2, 2006'`.
+func parseTag(tag string) (string, tagOptions, error) {
+	parts := splitTag(tag)
+
+	options := make(tagOptions, len(parts)-1)
+
+	for _, part := range parts[1:] {
+		key, value, _ := strings.Cut(part, "=")
+		key = strings.TrimSpace(key)
+
+		if !knownTagOptions[key] {
+			return "", nil, fmt.Errorf("option %q: %w", key, ErrInvalidTag)
+		}
+
+		if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+
+		options[key] = value
+	}
+
+	return parts[0], options, nil
+}
+
+// splitTag splits the tag on commas which are not wrapped in single quotes.
+func splitTag(tag string) []string {
+	var (
+		parts  []string
+		quoted bool
+		start  int
+	)
+
+	for i, r := range tag {
+		switch r {
+		case '\'':
+			quoted = !quoted
+		case ',':
+			if quoted {
+				continue
+			}
+
+			parts = append(parts, tag[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, tag[start:])
+}
+
+func (o tagOptions) has(key string) bool {
+	_, ok := o[key]
+
+	return ok
+}
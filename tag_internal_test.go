@@ -0,0 +1,66 @@
+package goflat
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseTag(t *testing.T) {
+	t.Run("error", testParseTagError)
+	t.Run("success", testParseTagSuccess)
+}
+
+func testParseTagError(t *testing.T) {
+	_, _, err := parseTag("name,unknown")
+	if !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("expected %v, got %v", ErrInvalidTag, err)
+	}
+}
+
+func testParseTagSuccess(t *testing.T) {
+	tcs := map[string]struct {
+		tag             string
+		expectedName    string
+		expectedOptions tagOptions
+	}{
+		"name only": {
+			tag:             "name",
+			expectedName:    "name",
+			expectedOptions: tagOptions{},
+		},
+		"empty": {
+			tag:             "",
+			expectedName:    "",
+			expectedOptions: tagOptions{},
+		},
+		"layout": {
+			tag:             "created_at,layout=2006-01-02",
+			expectedName:    "created_at",
+			expectedOptions: tagOptions{"layout": "2006-01-02"},
+		},
+		"quoted": {
+			tag:             "created_at,layout='Jan 2, 2006'",
+			expectedName:    "created_at",
+			expectedOptions: tagOptions{"layout": "Jan 2, 2006"},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			gotName, gotOptions, err := parseTag(tc.tag)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if gotName != tc.expectedName {
+				t.Errorf("expected name %q, got %q", tc.expectedName, gotName)
+			}
+
+			if diff := cmp.Diff(tc.expectedOptions, gotOptions); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
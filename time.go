@@ -0,0 +1,59 @@
+package goflat
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Special layouts which can be used in place of a [time.Time] layout, either
+// with the `layout` tag option or with [Options.TimeLayout], to work with Unix
+// epoch timestamps.
+const (
+	// LayoutUnix represents times as seconds since the Unix epoch.
+	LayoutUnix = "unix"
+	// LayoutUnixMilli represents times as milliseconds since the Unix epoch.
+	LayoutUnixMilli = "unixmilli"
+)
+
+// defaultTimeLayout is used when neither the tag nor the options specify a
+// layout. Unlike [time.RFC3339], it does not lose sub-second precision.
+const defaultTimeLayout = time.RFC3339Nano
+
+func parseTime(str, layout string, location *time.Location) (time.Time, error) {
+	switch layout {
+	case LayoutUnix, LayoutUnixMilli:
+		epoch, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parse epoch: %w", err)
+		}
+
+		if layout == LayoutUnix {
+			return time.Unix(epoch, 0).In(location), nil
+		}
+
+		return time.UnixMilli(epoch).In(location), nil
+	}
+
+	t, err := time.ParseInLocation(layout, str, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse time: %w", err)
+	}
+
+	return t, nil
+}
+
+func formatTime(t time.Time, layout string, location *time.Location) string {
+	if location != nil {
+		t = t.In(location)
+	}
+
+	switch layout {
+	case LayoutUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case LayoutUnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+
+	return t.Format(layout)
+}
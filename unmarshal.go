@@ -6,52 +6,172 @@ import (
 	"errors"
 	"fmt"
 	"io"
-
-	"golang.org/x/sync/errgroup"
+	"iter"
 )
 
 // Unmarshaller can be used to tell goflat to use custom logic to convert the
-// input string into the type itself.
+// input string into the type itself. It takes precedence over
+// [encoding.TextUnmarshaler].
 type Unmarshaller interface {
 	Unmarshal(value string) (Unmarshaller, error)
 }
 
-// UnmarshalToChannel unmarshals a CSV file to a channel of structs. It
-// automatically closes the channel at the end.
+// RowReader is implemented by the readers goflat can unmarshal from, such as
+// [*csv.Reader] and [*FixedWidthReader]. Read must return [io.EOF] once there
+// are no more rows. Unless [Options.Headerless] is set, the first row holds
+// the headers.
+//
+// Readers which also implement FieldPos, like [*csv.Reader], get the line of
+// the faulty row reported in errors.
+type RowReader interface {
+	Read() ([]string, error)
+}
+
+// fieldPositioner is implemented by readers which can report the position of
+// a field in the file, such as [*csv.Reader].
+type fieldPositioner interface {
+	FieldPos(field int) (line, column int)
+}
+
+// UnmarshalToIterator returns an iterator over the rows of a file. Rows
+// are read lazily on the caller's goroutine and reading stops as soon as the
+// consumer breaks out of the loop.
+//
+// Errors are yielded alongside the zero value of T: the consumer can decide
+// whether to keep iterating, in which case the iterator moves on to the next
+// row. Errors which prevent any further reading, such as failing to read the
+// headers or a cancelled context, end the iteration.
+//
+// With [Options.Workers], rows are still read and yielded in order but they
+// are unmarshalled concurrently, ahead of the consumer.
 //
 // An options struct can be passed to modify the behaviour, use [StrictOptions]
 // if you're not sure about how to configure them.
-func UnmarshalToChannel[T any](ctx context.Context, reader *csv.Reader, outputCh chan<- T, opts Options) error {
-	defer close(outputCh)
+func UnmarshalToIterator[T any](ctx context.Context, reader RowReader, opts Options) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
 
-	headers, err := reader.Read()
-	if err != nil {
-		return fmt.Errorf("read headers: %w", err)
-	}
+		decoder := NewDecoder[T](reader, opts)
 
-	factory, err := newFactory[T](headers, opts)
-	if err != nil {
-		return fmt.Errorf("new factory: %w", err)
-	}
+		err := decoder.init()
+		if err != nil {
+			yield(zero, err)
 
-	var currentLine int
+			return
+		}
 
-	for {
-		record, err := reader.Read()
-		if err != nil {
+		if opts.Workers > 1 {
+			unmarshalParallel(ctx, reader, decoder.factory, opts.Workers, yield)
+
+			return
+		}
+
+		for {
+			if ctx.Err() != nil {
+				yield(zero, context.Cause(ctx))
+
+				return
+			}
+
+			var value T
+
+			err := decoder.Decode(&value)
 			if errors.Is(err, io.EOF) {
-				return nil
+				return
+			}
+
+			if err != nil {
+				// Errors are only recoverable if the decoder can move on.
+				if !yield(zero, err) || decoder.err != nil {
+					return
+				}
+
+				continue
 			}
 
-			return fmt.Errorf("read row: %w", err)
+			if !yield(value, nil) {
+				return
+			}
 		}
+	}
+}
+
+// UnmarshalToChannel unmarshals a file to a channel of structs. It
+// automatically closes the channel at the end.
+//
+// An options struct can be passed to modify the behaviour, use [StrictOptions]
+// if you're not sure about how to configure them.
+func UnmarshalToChannel[T any](ctx context.Context, reader RowReader, outputCh chan<- T, opts Options) error {
+	return rowsToChannel(ctx, UnmarshalToIterator[T](ctx, reader, opts), outputCh, opts)
+}
+
+// UnmarshalToSlice unmarshals a file to a slice of structs. When
+// [Options.CollectErrors] is set, the rows which were unmarshalled
+// successfully are returned alongside the [RowErrors].
+//
+// An options struct can be passed to modify the behaviour, use [StrictOptions]
+// if you're not sure about how to configure them.
+func UnmarshalToSlice[T any](ctx context.Context, reader RowReader, opts Options) ([]T, error) {
+	return rowsToSlice(UnmarshalToIterator[T](ctx, reader, opts), opts)
+}
 
-		value, err := factory.unmarshal(record)
+// UnmarshalToCallback unamrshals a file invoking a callback function on
+// each row.
+//
+// An options struct can be passed to modify the behaviour, use [StrictOptions]
+// if you're not sure about how to configure them.
+func UnmarshalToCallback[T any](ctx context.Context, reader RowReader, opts Options, callback func(T) error) error {
+	return rowsToCallback(UnmarshalToIterator[T](ctx, reader, opts), opts, callback)
+}
+
+// lineFunc returns the line on which a field of a row starts.
+type lineFunc func(field int) int
+
+// readerLines returns the lines of the last row read by the reader, or nil if
+// the reader cannot report them.
+func readerLines(reader RowReader) lineFunc {
+	positioner, ok := reader.(fieldPositioner)
+	if !ok {
+		return nil
+	}
+
+	return func(field int) int {
+		line, _ := positioner.FieldPos(field)
+
+		return line
+	}
+}
+
+// withLine adds the line of the row to the given error, if known.
+func withLine(err error, lines lineFunc) error {
+	if lines == nil {
+		return err
+	}
+
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		parseErr.Line = lines(parseErr.Column)
+
+		return err
+	}
+
+	return fmt.Errorf("get struct at line %d: %w", lines(0), err)
+}
+
+func rowsToChannel[T any](ctx context.Context, seq iter.Seq2[T, error], outputCh chan<- T, opts Options) error {
+	defer close(outputCh)
+
+	collector := errorCollector{options: opts}
+
+	for value, err := range seq {
 		if err != nil {
-			return fmt.Errorf("get struct at line %d: %w", currentLine, err)
-		}
+			err = collector.collect(err)
+			if err != nil {
+				return err
+			}
 
-		currentLine++
+			continue
+		}
 
 		select {
 		case <-ctx.Done():
@@ -59,68 +179,91 @@ func UnmarshalToChannel[T any](ctx context.Context, reader *csv.Reader, outputCh
 		case outputCh <- value:
 		}
 	}
-}
-
-// UnmarshalToSlice unmarshals a CSV file to a slice of structs.
-//
-// An options struct can be passed to modify the behaviour, use [StrictOptions]
-// if you're not sure about how to configure them.
-func UnmarshalToSlice[T any](ctx context.Context, reader *csv.Reader, opts Options) ([]T, error) {
-	g, ctx := errgroup.WithContext(ctx) //nolint:varnamelen // Fine here.
 
-	ch := make(chan T) //nolint:varnamelen // Fine here.
+	return collector.result()
+}
 
+func rowsToSlice[T any](seq iter.Seq2[T, error], opts Options) ([]T, error) {
 	var slice []T
 
-	g.Go(func() error {
-		for v := range ch {
-			slice = append(slice, v)
-		}
+	collector := errorCollector{options: opts}
 
-		return nil
-	})
+	for value, err := range seq {
+		if err != nil {
+			err = collector.collect(err)
+			if err != nil {
+				return nil, err
+			}
 
-	g.Go(func() error {
-		return UnmarshalToChannel(ctx, reader, ch, opts)
-	})
+			continue
+		}
 
-	err := g.Wait()
-	if err != nil {
-		return nil, fmt.Errorf("wait: %w", err)
+		slice = append(slice, value)
 	}
 
-	return slice, nil
+	return slice, collector.result()
 }
 
-// UnmarshalToCallback unamrshals a CSV file invoking a callback function on
-// each row.
-//
-// An options struct can be passed to modify the behaviour, use [StrictOptions]
-// if you're not sure about how to configure them.
-func UnmarshalToCallback[T any](ctx context.Context, reader *csv.Reader, opts Options, callback func(T) error) error {
-	g, ctx := errgroup.WithContext(ctx) //nolint:varnamelen // Fine here.
-
-	ch := make(chan T) //nolint:varnamelen // Fine here.
+func rowsToCallback[T any](seq iter.Seq2[T, error], opts Options, callback func(T) error) error {
+	collector := errorCollector{options: opts}
 
-	g.Go(func() error {
-		for v := range ch {
-			err := callback(v)
+	for value, err := range seq {
+		if err != nil {
+			err = collector.collect(err)
 			if err != nil {
-				return fmt.Errorf("callback: %w", err)
+				return err
 			}
+
+			continue
 		}
 
-		return nil
-	})
+		err = callback(value)
+		if err != nil {
+			return fmt.Errorf("callback: %w", err)
+		}
+	}
+
+	return collector.result()
+}
 
-	g.Go(func() error {
-		return UnmarshalToChannel(ctx, reader, ch, opts)
-	})
+// errorCollector implements the error handling of [Options.CollectErrors].
+type errorCollector struct {
+	options Options
+	errs    RowErrors
+}
 
-	err := g.Wait()
-	if err != nil {
-		return fmt.Errorf("wait: %w", err)
+// collect returns the given error if the unmarshalling must be aborted, nil
+// otherwise.
+func (e *errorCollector) collect(err error) error {
+	if !e.options.CollectErrors || !isRowError(err) {
+		return err
+	}
+
+	e.errs = append(e.errs, err)
+
+	if e.options.MaxErrors > 0 && len(e.errs) > e.options.MaxErrors {
+		return fmt.Errorf("%w: %w", ErrTooManyErrors, e.errs)
 	}
 
 	return nil
 }
+
+// result returns the collected errors, if any.
+func (e *errorCollector) result() error {
+	if len(e.errs) == 0 {
+		return nil
+	}
+
+	return e.errs
+}
+
+// isRowError returns whether the error only affects a single row, meaning
+// that the rest of the file can still be processed.
+func isRowError(err error) bool {
+	var (
+		parseErr    *ParseError
+		csvParseErr *csv.ParseError
+	)
+
+	return errors.As(err, &parseErr) || errors.As(err, &csvParseErr)
+}
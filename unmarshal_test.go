@@ -2,11 +2,20 @@ package goflat_test
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/netip"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 
 	"github.com/lzambarda/goflat"
 )
@@ -22,6 +31,9 @@ var testdata embed.FS
 
 func testUnmarshalError(t *testing.T) {
 	t.Run("empty", testUnmarshalErrorEmpty)
+	t.Run("collect", testUnmarshalErrorCollect)
+	t.Run("parse error", testUnmarshalErrorParseError)
+	t.Run("required", testUnmarshalErrorRequired)
 }
 
 func testUnmarshalErrorEmpty(t *testing.T) {
@@ -59,12 +71,166 @@ func testUnmarshalErrorEmpty(t *testing.T) {
 	}
 }
 
+func testUnmarshalErrorCollect(t *testing.T) {
+	type record struct {
+		Name   string  `flat:"name"`
+		Age    int     `flat:"age"`
+		Height float32 `flat:"height"`
+	}
+
+	input := `name,age,height
+Guybrush,28,1.78
+Elaine,twenty,1.60
+LeChuck,100,tall
+Stan,45,1.80
+`
+
+	t.Run("all", func(t *testing.T) {
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+			CollectErrors: true,
+		})
+
+		expected := []record{
+			{Name: "Guybrush", Age: 28, Height: 1.78},
+			{Name: "Stan", Age: 45, Height: 1.8},
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+
+		var rowErrors goflat.RowErrors
+		if !errors.As(err, &rowErrors) {
+			t.Fatalf("expected %T, got %v", rowErrors, err)
+		}
+
+		gotErrors := make([]goflat.ParseError, 0, len(rowErrors))
+
+		for _, rowErr := range rowErrors {
+			var parseErr *goflat.ParseError
+			if !errors.As(rowErr, &parseErr) {
+				t.Fatalf("expected %T, got %v", parseErr, rowErr)
+			}
+
+			gotErrors = append(gotErrors, *parseErr)
+		}
+
+		expectedErrors := []goflat.ParseError{
+			{Line: 3, Column: 1, Header: "age", Field: "Age", Value: "twenty"},
+			{Line: 4, Column: 2, Header: "height", Field: "Height", Value: "tall"},
+		}
+
+		if diff := cmp.Diff(expectedErrors, gotErrors, cmpopts.IgnoreFields(goflat.ParseError{}, "Err")); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		channel := make(chan record)
+		assertChannel(t, channel, []record{{Name: "Guybrush", Age: 28, Height: 1.78}})
+
+		err := goflat.UnmarshalToChannel(t.Context(), csv.NewReader(bytes.NewBufferString(input)), channel, goflat.Options{
+			CollectErrors: true,
+			MaxErrors:     1,
+		})
+		if !errors.Is(err, goflat.ErrTooManyErrors) {
+			t.Errorf("expected %v, got %v", goflat.ErrTooManyErrors, err)
+		}
+	})
+}
+
+func testUnmarshalErrorParseError(t *testing.T) {
+	type address struct {
+		Street string `flat:"street"`
+		Number int    `flat:"number"`
+	}
+
+	type record struct {
+		Name    string  `flat:"name"`
+		Address address `flat:"address"`
+	}
+
+	// The first record spans two lines.
+	input := `name,address.street,address.number
+"Guybrush
+Threepwood",Main St,1
+Elaine,Mansion,one
+`
+
+	_, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+
+	var parseErr *goflat.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected %T, got %v", parseErr, err)
+	}
+
+	expected := goflat.ParseError{
+		Line:   4,
+		Column: 2,
+		Header: "address.number",
+		Field:  "Address.Number",
+		Value:  "one",
+	}
+
+	if diff := cmp.Diff(expected, *parseErr, cmpopts.IgnoreFields(goflat.ParseError{}, "Err")); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("expected %v, got %v", strconv.ErrSyntax, err)
+	}
+}
+
+func testUnmarshalErrorRequired(t *testing.T) {
+	type record struct {
+		Name string `flat:"name,required"`
+		Age  int    `flat:"age"`
+	}
+
+	t.Run("missing header", func(t *testing.T) {
+		input := `age
+28
+`
+
+		_, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+		if !errors.Is(err, goflat.ErrMissingHeader) {
+			t.Errorf("expected %v, got %v", goflat.ErrMissingHeader, err)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		input := `name,age
+Guybrush,28
+,20
+`
+
+		_, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+			UnmarshalIgnoreEmpty: true,
+		})
+		if !errors.Is(err, goflat.ErrMissingValue) {
+			t.Errorf("expected %v, got %v", goflat.ErrMissingValue, err)
+		}
+	})
+}
+
 func testUnmarshalSuccess(t *testing.T) {
 	t.Run("full", testUnmarshalSuccessFull)
 	t.Run("ignore empty", testUnmarshalSuccessIgnoreEmpty)
 	t.Run("pointer", testUnmarshalSuccessPointer)
 	t.Run("slice", testUnmarshalSuccessSlice)
 	t.Run("callback", testUnmarshalSuccessCallback)
+	t.Run("nested", testUnmarshalSuccessNested)
+	t.Run("iterator", testUnmarshalSuccessIterator)
+	t.Run("default", testUnmarshalSuccessDefault)
+	t.Run("aliases", testUnmarshalSuccessAliases)
+	t.Run("extra", testUnmarshalSuccessExtra)
+	t.Run("headerless", testUnmarshalSuccessHeaderless)
+	t.Run("row reader", testUnmarshalSuccessRowReader)
+	t.Run("parallel", testUnmarshalSuccessParallel)
+	t.Run("repeated", testUnmarshalSuccessRepeated)
+	t.Run("map", testUnmarshalSuccessMap)
+	t.Run("json", testUnmarshalSuccessJSON)
+	t.Run("number format", testUnmarshalSuccessNumberFormat)
 }
 
 func testUnmarshalSuccessFull(t *testing.T) {
@@ -354,8 +520,872 @@ func testUnmarshalSuccessCallback(t *testing.T) {
 	}
 }
 
+func testUnmarshalSuccessNested(t *testing.T) {
+	type address struct {
+		Street string `flat:"street"`
+		City   string `flat:"city"`
+	}
+
+	type Base struct {
+		ID int `flat:"id"`
+	}
+
+	type record struct {
+		Base
+		Name     string   `flat:"name"`
+		Address  address  `flat:"address"`
+		Previous *address `flat:"previous"`
+	}
+
+	input := `id,name,address.street,address.city,previous.street,previous.city
+1,Guybrush,Main St,Melee Island,,
+2,Elaine,Mansion,Melee Island,Governor's House,Booty Island
+3,LeChuck,Ghost Ship,Monkey Island,nil,nil
+4,Stan,nil,Melee Island,nil,Melee Island
+`
+
+	expected := []record{
+		{
+			Base:    Base{ID: 1},
+			Name:    "Guybrush",
+			Address: address{Street: "Main St", City: "Melee Island"},
+		},
+		{
+			Base:     Base{ID: 2},
+			Name:     "Elaine",
+			Address:  address{Street: "Mansion", City: "Melee Island"},
+			Previous: &address{Street: "Governor's House", City: "Booty Island"},
+		},
+		{
+			Base:    Base{ID: 3},
+			Name:    "LeChuck",
+			Address: address{Street: "Ghost Ship", City: "Monkey Island"},
+		},
+		{
+			Base:     Base{ID: 4},
+			Name:     "Stan",
+			Address:  address{Street: "nil", City: "Melee Island"},
+			Previous: &address{Street: "nil", City: "Melee Island"},
+		},
+	}
+
+	got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+		ErrorIfTaglessField:     true,
+		ErrorIfDuplicateHeaders: true,
+		ErrorIfMissingHeaders:   true,
+		UnmarshalIgnoreEmpty:    true,
+	})
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+}
+
+func testUnmarshalSuccessIterator(t *testing.T) {
+	type record struct {
+		Name string `flat:"name"`
+		Age  int    `flat:"age"`
+	}
+
+	input := `name,age
+Guybrush,28
+Elaine,twenty
+LeChuck,100
+Stan,45
+`
+
+	t.Run("continue on error", func(t *testing.T) {
+		reader := csv.NewReader(bytes.NewBufferString(input))
+
+		var (
+			got       []record
+			errorRows int
+		)
+
+		for value, err := range goflat.UnmarshalToIterator[record](t.Context(), reader, goflat.Options{}) {
+			if err != nil {
+				errorRows++
+
+				continue
+			}
+
+			got = append(got, value)
+		}
+
+		expected := []record{
+			{Name: "Guybrush", Age: 28},
+			{Name: "LeChuck", Age: 100},
+			{Name: "Stan", Age: 45},
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+
+		if errorRows != 1 {
+			t.Errorf("expected 1 error, got %d", errorRows)
+		}
+	})
+
+	t.Run("break", func(t *testing.T) {
+		reader := csv.NewReader(bytes.NewBufferString(input))
+
+		for value, err := range goflat.UnmarshalToIterator[record](t.Context(), reader, goflat.Options{}) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if value.Name != "Guybrush" {
+				t.Errorf("expected Guybrush, got %q", value.Name)
+			}
+
+			break
+		}
+
+		// The iterator must not have read past the first row.
+		next, err := reader.Read()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+
+		if diff := cmp.Diff([]string{"Elaine", "twenty"}, next); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		reader := csv.NewReader(bytes.NewBufferString(input))
+
+		var iterations int
+
+		for _, err := range goflat.UnmarshalToIterator[record](ctx, reader, goflat.Options{}) {
+			iterations++
+
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected %v, got %v", context.Canceled, err)
+			}
+		}
+
+		if iterations != 1 {
+			t.Errorf("expected 1 iteration, got %d", iterations)
+		}
+	})
+}
+
+func testUnmarshalSuccessDefault(t *testing.T) {
+	type record struct {
+		Name    string  `flat:"name"`
+		Age     int     `flat:"age,default=18"`
+		Country string  `flat:"country,default=Caribbean"`
+		Ship    *string `flat:"ship,default='The Sea Cucumber'"`
+	}
+
+	input := `name,age
+Guybrush,28
+Elaine,
+`
+
+	expected := []record{
+		{Name: "Guybrush", Age: 28, Country: "Caribbean", Ship: ptrTo("The Sea Cucumber")},
+		{Name: "Elaine", Age: 18, Country: "Caribbean", Ship: ptrTo("The Sea Cucumber")},
+	}
+
+	got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+		ErrorIfMissingHeaders: true,
+	})
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+}
+
+func testUnmarshalSuccessAliases(t *testing.T) {
+	type address struct {
+		City string `flat:"city|town"`
+	}
+
+	type record struct {
+		FirstName string  `flat:"first_name|firstname|given_name"`
+		Age       int     `flat:"age"`
+		Address   address `flat:"address|addr"`
+	}
+
+	tcs := map[string]string{
+		"canonical":  "first_name,age,address.city\nGuybrush,28,Melee\n",
+		"alias":      "given_name,age,addr.town\nGuybrush,28,Melee\n",
+		"normalized": "\uFEFF First Name ,AGE,ADDR.Town\nGuybrush,28,Melee\n",
+	}
+
+	expected := []record{{FirstName: "Guybrush", Age: 28, Address: address{City: "Melee"}}}
+
+	for name, input := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+				ErrorIfMissingHeaders: true,
+				HeaderNormalization:   goflat.NormalizeAll,
+			})
+			if err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+
+			if diff := cmp.Diff(expected, got); diff != "" {
+				t.Errorf("(-expected,+got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("not normalized", func(t *testing.T) {
+		_, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(tcs["normalized"])), goflat.Options{
+			ErrorIfMissingHeaders: true,
+		})
+		if !errors.Is(err, goflat.ErrMissingHeader) {
+			t.Errorf("expected %v, got %v", goflat.ErrMissingHeader, err)
+		}
+	})
+}
+
+func testUnmarshalSuccessExtra(t *testing.T) {
+	type record struct {
+		Name  string            `flat:"name"`
+		Extra map[string]string `flat:",extra"`
+	}
+
+	input := `name,ship,crew
+Guybrush,The Sea Cucumber,3
+LeChuck,,
+`
+
+	t.Run("default", func(t *testing.T) {
+		expected := []record{
+			{Name: "Guybrush", Extra: map[string]string{"ship": "The Sea Cucumber", "crew": "3"}},
+			{Name: "LeChuck", Extra: map[string]string{"ship": "", "crew": ""}},
+		}
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("ignore empty", func(t *testing.T) {
+		expected := []record{
+			{Name: "Guybrush", Extra: map[string]string{"ship": "The Sea Cucumber", "crew": "3"}},
+			{Name: "LeChuck"},
+		}
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+			UnmarshalIgnoreEmpty: true,
+		})
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+}
+
+func testUnmarshalSuccessRepeated(t *testing.T) {
+	type item struct {
+		SKU string `flat:"sku"`
+		Qty *int   `flat:"qty"`
+	}
+
+	type record struct {
+		Name   string            `flat:"name"`
+		Phones []string          `flat:"phone_{n}"`
+		Items  []item            `flat:"item_{n}"`
+		Extra  map[string]string `flat:",extra"`
+	}
+
+	t.Run("default", func(t *testing.T) {
+		input := `name,phone_2,item_1.sku,phone_1,item_1.qty,item_2.sku,phone_x,item_2.qty
+Guybrush,555-0102,ROOT-BEER,555-0101,2,,home,
+LeChuck,,,,,MAP,,nil
+Elaine,555-0301,,,,,,
+`
+
+		expected := []record{
+			{
+				Name:   "Guybrush",
+				Phones: []string{"555-0101", "555-0102"},
+				Items:  []item{{SKU: "ROOT-BEER", Qty: ptrTo(2)}},
+				Extra:  map[string]string{"phone_x": "home"},
+			},
+			{
+				Name:  "LeChuck",
+				Items: []item{{}, {SKU: "MAP"}},
+				Extra: map[string]string{"phone_x": ""},
+			},
+			{
+				Name:   "Elaine",
+				Phones: []string{"", "555-0301"},
+				Extra:  map[string]string{"phone_x": ""},
+			},
+		}
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("pointer items", func(t *testing.T) {
+		type record struct {
+			Items []*item `flat:"item_{n}"`
+		}
+
+		input := `item_1.sku,item_1.qty,item_2.sku,item_2.qty
+nil,nil,nil,3
+`
+
+		expected := []record{{Items: []*item{nil, {SKU: "nil", Qty: ptrTo(3)}}}}
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("max", func(t *testing.T) {
+		type record struct {
+			Phones []string `flat:"phone_{n},max=2"`
+		}
+
+		input := `phone_1,phone_2,phone_3
+555-0101,555-0102,555-0103
+`
+
+		expected := []record{{Phones: []string{"555-0101", "555-0102"}}}
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		input := `name,item_1.sku,item_1.qty
+Guybrush,ROOT-BEER,many
+`
+
+		_, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+
+		var parseErr *goflat.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("expected a parse error, got %v", err)
+		}
+
+		if parseErr.Field != "Items[0].Qty" {
+			t.Errorf("expected field %q, got %q", "Items[0].Qty", parseErr.Field)
+		}
+	})
+
+	t.Run("duplicated header", func(t *testing.T) {
+		input := `phone_1,phone_01
+555-0101,555-0102
+`
+
+		_, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+			ErrorIfDuplicateHeaders: true,
+		})
+		if !errors.Is(err, goflat.ErrDuplicatedHeader) {
+			t.Errorf("expected %v, got %v", goflat.ErrDuplicatedHeader, err)
+		}
+	})
+}
+
+func testUnmarshalSuccessMap(t *testing.T) {
+	type record struct {
+		Name   string            `flat:"name"`
+		Counts map[string]int    `flat:"counts"`
+		Tags   map[string]string `flat:"tags,sep=' | ',kvsep=:"`
+		Attrs  map[string]*int   `flat:"attr,expand"`
+		Extra  map[string]string `flat:",extra"`
+	}
+
+	input := `name,counts,tags,attr.size,attr.weight,color
+Guybrush,gold=8;rum=2,ship:The Sea Cucumber | crew:3,12,nil,
+LeChuck,,nil,,,red
+`
+
+	t.Run("default", func(t *testing.T) {
+		expected := []record{
+			{
+				Name:   "Guybrush",
+				Counts: map[string]int{"gold": 8, "rum": 2},
+				Tags:   map[string]string{"ship": "The Sea Cucumber", "crew": "3"},
+				Attrs:  map[string]*int{"size": ptrTo(12), "weight": nil},
+				Extra:  map[string]string{"color": ""},
+			},
+			{
+				Name:   "LeChuck",
+				Counts: map[string]int{},
+				Extra:  map[string]string{"color": "red"},
+			},
+		}
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		input := `name,counts,attr.size
+Guybrush,gold=8,large
+`
+
+		_, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+
+		var parseErr *goflat.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("expected a parse error, got %v", err)
+		}
+
+		if parseErr.Field != "Attrs" || parseErr.Header != "attr.size" {
+			t.Errorf("expected field Attrs and header attr.size, got %q and %q", parseErr.Field, parseErr.Header)
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		type record struct {
+			Sizes map[int]int       `flat:"attr,expand"`
+			Extra map[string]string `flat:",extra"`
+		}
+
+		input := `attr.1,attr.total,attr.2
+10,30,20
+`
+
+		expected := []record{{Sizes: map[int]int{1: 10, 2: 20}, Extra: map[string]string{"attr.total": "30"}}}
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+
+		_, err = goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+			ErrorIfMissingHeaders: true,
+		})
+		if err == nil {
+			t.Error("expected an error with ErrorIfMissingHeaders, got nil")
+		}
+	})
+}
+
+func testUnmarshalSuccessJSON(t *testing.T) {
+	type payload struct {
+		Ship string   `json:"ship"`
+		Crew []string `json:"crew"`
+	}
+
+	type record struct {
+		Name    string         `flat:"name"`
+		Payload *payload       `flat:"payload,json"`
+		Meta    map[string]any `flat:"meta,json"`
+		Scores  []int          `flat:"scores,json"`
+	}
+
+	input := `name,payload,meta,scores
+Guybrush,"{""ship"":""The Sea Cucumber"",""crew"":[""Otis"",""Carla""]}","{""cursed"":false}","[1,2]"
+LeChuck,nil,{},[]
+`
+
+	t.Run("default", func(t *testing.T) {
+		expected := []record{
+			{
+				Name:    "Guybrush",
+				Payload: &payload{Ship: "The Sea Cucumber", Crew: []string{"Otis", "Carla"}},
+				Meta:    map[string]any{"cursed": false},
+				Scores:  []int{1, 2},
+			},
+			{
+				Name:   "LeChuck",
+				Meta:   map[string]any{},
+				Scores: []int{},
+			},
+		}
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{})
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		input := `name,payload
+Guybrush,{"ship":
+`
+
+		reader := csv.NewReader(bytes.NewBufferString(input))
+		reader.LazyQuotes = true
+
+		_, err := goflat.UnmarshalToSlice[record](t.Context(), reader, goflat.Options{})
+
+		var parseErr *goflat.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("expected a parse error, got %v", err)
+		}
+
+		if parseErr.Field != "Payload" || parseErr.Column != 1 {
+			t.Errorf("expected field Payload at column 1, got %q at column %d", parseErr.Field, parseErr.Column)
+		}
+	})
+}
+
+func testUnmarshalSuccessNumberFormat(t *testing.T) {
+	type record struct {
+		Name     string  `flat:"name"`
+		Amount   float64 `flat:"amount"`
+		Quantity int     `flat:"quantity"`
+		Discount float64 `flat:"discount,percent"`
+		Balance  int64   `flat:"balance,decimal=.,thousands=',',currency=$,accounting"`
+	}
+
+	input := `name;amount;quantity;discount;balance
+Guybrush;1.234,56;1.000;12,5%;($1,200)
+LeChuck;-0,5;7;3;$300
+`
+
+	expected := []record{
+		{Name: "Guybrush", Amount: 1234.56, Quantity: 1000, Discount: 0.125, Balance: -1200},
+		{Name: "LeChuck", Amount: -0.5, Quantity: 7, Discount: 0.03, Balance: 300},
+	}
+
+	csvReader, err := goflat.DetectReader(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("detect reader: %v", err)
+	}
+
+	got, err := goflat.UnmarshalToSlice[record](t.Context(), csvReader, goflat.Options{
+		NumberFormat: goflat.NumberFormat{Decimal: ',', Thousands: '.'},
+	})
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+}
+
+func testUnmarshalSuccessRowReader(t *testing.T) {
+	type record struct {
+		Name string `flat:"name"`
+		Age  int    `flat:"age"`
+	}
+
+	reader := &tableReader{rows: [][]string{
+		{"age", "name"},
+		{"28", "Guybrush"},
+		{"20", "Elaine"},
+	}}
+
+	expected := []record{
+		{Name: "Guybrush", Age: 28},
+		{Name: "Elaine", Age: 20},
+	}
+
+	got, err := goflat.UnmarshalToSlice[record](t.Context(), reader, goflat.Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func testUnmarshalSuccessParallel(t *testing.T) {
+	type record struct {
+		ID    int     `flat:"id"`
+		Value float64 `flat:"value"`
+	}
+
+	const rows = 1000
+
+	var (
+		input    bytes.Buffer
+		expected []record
+	)
+
+	input.WriteString("id,value\n")
+
+	for i := range rows {
+		fmt.Fprintf(&input, "%d,%d.5\n", i, i)
+
+		expected = append(expected, record{ID: i, Value: float64(i) + 0.5})
+	}
+
+	opts := goflat.Options{Workers: 8}
+
+	t.Run("order", func(t *testing.T) {
+		reader := csv.NewReader(bytes.NewReader(input.Bytes()))
+		reader.ReuseRecord = true
+
+		channel := make(chan record)
+		assertChannel(t, channel, expected)
+
+		err := goflat.UnmarshalToChannel(t.Context(), reader, channel, opts)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		input := `id,value
+1,1.5
+"2
+",2.5
+two,3.5
+4,four
+5,5.5
+`
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+			Workers:       4,
+			CollectErrors: true,
+		})
+
+		if diff := cmp.Diff([]record{{ID: 1, Value: 1.5}, {ID: 5, Value: 5.5}}, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+
+		var rowErrors goflat.RowErrors
+		if !errors.As(err, &rowErrors) {
+			t.Fatalf("expected %T, got %v", rowErrors, err)
+		}
+
+		gotErrors := make([]goflat.ParseError, 0, len(rowErrors))
+
+		for _, rowErr := range rowErrors {
+			var parseErr *goflat.ParseError
+			if !errors.As(rowErr, &parseErr) {
+				t.Fatalf("expected %T, got %v", parseErr, rowErr)
+			}
+
+			gotErrors = append(gotErrors, *parseErr)
+		}
+
+		// The lines are those of the faulty rows, not of the last row read.
+		expectedErrors := []goflat.ParseError{
+			{Line: 3, Column: 0, Header: "id", Field: "ID", Value: "2\n"},
+			{Line: 5, Column: 0, Header: "id", Field: "ID", Value: "two"},
+			{Line: 6, Column: 1, Header: "value", Field: "Value", Value: "four"},
+		}
+
+		if diff := cmp.Diff(expectedErrors, gotErrors, cmpopts.IgnoreFields(goflat.ParseError{}, "Err")); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("break", func(t *testing.T) {
+		var got []record
+
+		for value, err := range goflat.UnmarshalToIterator[record](t.Context(), csv.NewReader(bytes.NewReader(input.Bytes())), opts) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got = append(got, value)
+			if len(got) == 10 {
+				break
+			}
+		}
+
+		if diff := cmp.Diff(expected[:10], got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		var (
+			got     []record
+			lastErr error
+		)
+
+		for value, err := range goflat.UnmarshalToIterator[record](ctx, csv.NewReader(bytes.NewReader(input.Bytes())), opts) {
+			if err != nil {
+				lastErr = err
+
+				continue
+			}
+
+			got = append(got, value)
+			if len(got) == 10 {
+				cancel()
+			}
+		}
+
+		if !errors.Is(lastErr, context.Canceled) {
+			t.Errorf("expected %v, got %v", context.Canceled, lastErr)
+		}
+
+		if diff := cmp.Diff(expected[:10], got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+}
+
+func testUnmarshalSuccessHeaderless(t *testing.T) {
+	input := `Guybrush,Threepwood,28
+Elaine,Marley,20
+`
+
+	t.Run("positional", func(t *testing.T) {
+		type record struct {
+			FirstName string `flat:"#0"`
+			Age       int    `flat:"age,index=2"`
+		}
+
+		expected := []record{
+			{FirstName: "Guybrush", Age: 28},
+			{FirstName: "Elaine", Age: 20},
+		}
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+			ErrorIfMissingHeaders: true,
+			Headerless:            true,
+		})
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("explicit headers", func(t *testing.T) {
+		type record struct {
+			FirstName string `flat:"first_name"`
+			LastName  string `flat:"last_name"`
+			Age       int    `flat:"age"`
+		}
+
+		expected := []record{
+			{FirstName: "Guybrush", LastName: "Threepwood", Age: 28},
+			{FirstName: "Elaine", LastName: "Marley", Age: 20},
+		}
+
+		reader := goflat.WithHeaders(csv.NewReader(bytes.NewBufferString(input)), "first_name", "last_name", "age")
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), reader, goflat.Options{
+			ErrorIfMissingHeaders: true,
+		})
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("explicit headers line", func(t *testing.T) {
+		type record struct {
+			Age int `flat:"age"`
+		}
+
+		reader := goflat.WithHeaders(csv.NewReader(bytes.NewBufferString("28\ntwenty\n")), "age")
+
+		_, err := goflat.UnmarshalToSlice[record](t.Context(), reader, goflat.Options{})
+
+		var parseErr *goflat.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("expected a parse error, got %v", err)
+		}
+
+		if parseErr.Line != 2 {
+			t.Errorf("expected line 2, got %d", parseErr.Line)
+		}
+	})
+
+	t.Run("explicit headers and headerless", func(t *testing.T) {
+		type record struct {
+			Age int `flat:"age"`
+		}
+
+		reader := goflat.WithHeaders(csv.NewReader(bytes.NewBufferString("28\n")), "age")
+
+		_, err := goflat.UnmarshalToSlice[record](t.Context(), reader, goflat.Options{Headerless: true})
+		if !errors.Is(err, goflat.ErrHeaderlessWithHeaders) {
+			t.Errorf("expected %v, got %v", goflat.ErrHeaderlessWithHeaders, err)
+		}
+	})
+
+	t.Run("positional with headers", func(t *testing.T) {
+		type record struct {
+			Surname string `flat:"#1"`
+			Age     int    `flat:"age"`
+		}
+
+		withHeaders := "first_name,last_name,age\n" + input
+
+		expected := []record{
+			{Surname: "Threepwood", Age: 28},
+			{Surname: "Marley", Age: 20},
+		}
+
+		got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(withHeaders)), goflat.Options{
+			ErrorIfMissingHeaders: true,
+		})
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("(-expected,+got):\n%s", diff)
+		}
+	})
+}
+
 func testUnmarshalType(t *testing.T) {
 	t.Run("int64 slice", testUnmarshalTypeInt64Slice)
+	t.Run("interfaces", testUnmarshalTypeInterfaces)
+	t.Run("time", testUnmarshalTypeTime)
 }
 
 func testUnmarshalTypeInt64Slice(t *testing.T) {
@@ -382,3 +1412,121 @@ func testUnmarshalTypeInt64Slice(t *testing.T) {
 		t.Errorf("(-expected,+got):\n%s", diff)
 	}
 }
+
+type unmarshalAll struct {
+	Value string
+}
+
+//nolint:ireturn // Required by the interface.
+func (unmarshalAll) Unmarshal(value string) (goflat.Unmarshaller, error) {
+	return unmarshalAll{Value: "goflat " + value}, nil
+}
+
+func (u *unmarshalAll) UnmarshalText(text []byte) error {
+	u.Value = "text " + string(text)
+
+	return nil
+}
+
+type unmarshalText struct {
+	Value string
+}
+
+func (u *unmarshalText) UnmarshalText(text []byte) error {
+	u.Value = "text " + string(text)
+
+	return nil
+}
+
+func testUnmarshalTypeInterfaces(t *testing.T) {
+	type record struct {
+		All    unmarshalAll  `flat:"all"`
+		Text   unmarshalText `flat:"text"`
+		Addr   netip.Addr    `flat:"addr"`
+		Amount *big.Int      `flat:"amount"`
+		Level  slog.Level    `flat:"level"`
+	}
+
+	input := `all,text,addr,amount,level
+a,b,192.168.0.1,123456789,WARN`
+
+	expected := []record{
+		{
+			All:    unmarshalAll{Value: "goflat a"},
+			Text:   unmarshalText{Value: "text b"},
+			Addr:   netip.MustParseAddr("192.168.0.1"),
+			Amount: big.NewInt(123456789),
+			Level:  slog.LevelWarn,
+		},
+	}
+
+	got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+		ErrorIfTaglessField:     true,
+		ErrorIfDuplicateHeaders: true,
+		ErrorIfMissingHeaders:   true,
+	})
+	if err != nil {
+		t.Fatalf("unmarshal to slice: %v", err)
+	}
+
+	comparers := []cmp.Option{
+		cmp.Comparer(func(a, b netip.Addr) bool { return a == b }),
+		cmp.Comparer(func(a, b *big.Int) bool { return a.Cmp(b) == 0 }),
+	}
+
+	if diff := cmp.Diff(expected, got, comparers...); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+}
+
+func testUnmarshalTypeTime(t *testing.T) {
+	type record struct {
+		Default   time.Time     `flat:"default"`
+		Date      time.Time     `flat:"date,layout=2006-01-02"`
+		Quoted    time.Time     `flat:"quoted,layout='Jan 2, 2006 15:04'"`
+		Epoch     time.Time     `flat:"epoch,layout=unix"`
+		EpochMs   time.Time     `flat:"epoch_ms,layout=unixmilli"`
+		Optional  *time.Time    `flat:"optional"`
+		Duration  time.Duration `flat:"duration"`
+		Undefined *time.Time    `flat:"undefined"`
+	}
+
+	input := `default,date,quoted,epoch,epoch_ms,optional,duration,undefined
+2024-03-01T10:20:30+01:00,2024-03-01,"Mar 1, 2024 10:20",1709284830,1709284830123,2024-03-01T10:20:30Z,1h30m,`
+
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Skipf("load location: %v", err)
+	}
+
+	expected := []record{
+		{
+			Default:  time.Date(2024, 3, 1, 10, 20, 30, 0, time.FixedZone("", 3600)),
+			Date:     time.Date(2024, 3, 1, 0, 0, 0, 0, rome),
+			Quoted:   time.Date(2024, 3, 1, 10, 20, 0, 0, rome),
+			Epoch:    time.Date(2024, 3, 1, 9, 20, 30, 0, time.UTC),
+			EpochMs:  time.Date(2024, 3, 1, 9, 20, 30, 123000000, time.UTC),
+			Optional: ptrTo(time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC)),
+			Duration: time.Hour + 30*time.Minute,
+		},
+	}
+
+	got, err := goflat.UnmarshalToSlice[record](t.Context(), csv.NewReader(bytes.NewBufferString(input)), goflat.Options{
+		ErrorIfTaglessField:     true,
+		ErrorIfDuplicateHeaders: true,
+		ErrorIfMissingHeaders:   true,
+		UnmarshalIgnoreEmpty:    true,
+		TimeLocation:            rome,
+	})
+	if err != nil {
+		t.Fatalf("unmarshal to slice: %v", err)
+	}
+
+	comparers := []cmp.Option{
+		cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) }),
+	}
+
+	if diff := cmp.Diff(expected, got, comparers...); diff != "" {
+		t.Errorf("(-expected,+got):\n%s", diff)
+	}
+}